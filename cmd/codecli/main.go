@@ -20,7 +20,7 @@ func main() {
 	cli.AddCommands(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,103 @@
+// Package agent runs the tool calling loop between the chat model and the
+// registered tools
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/azhany/codecli/internal/llm"
+	"github.com/azhany/codecli/internal/tools"
+	"github.com/azhany/codecli/internal/types"
+)
+
+// DefaultMaxSteps is the default limit on model requests per turn
+const DefaultMaxSteps = 10
+
+// Agent answers a conversation turn, letting the model call tools until it
+// replies without requesting any
+type Agent struct {
+	client   *llm.Client
+	tools    *tools.Manager
+	MaxSteps int // Model requests per turn before giving up
+
+	// OnToolCall, if set, is called before each tool runs
+	OnToolCall func(call llm.ToolCall)
+	// OnToolResult, if set, is called with the content sent back to the model
+	OnToolResult func(call llm.ToolCall, result *types.ToolResult)
+}
+
+// New creates an agent that offers the tools registered with manager
+func New(client *llm.Client, manager *tools.Manager) *Agent {
+	return &Agent{
+		client:   client,
+		tools:    manager,
+		MaxSteps: DefaultMaxSteps,
+	}
+}
+
+// Definitions describes the registered tools for the model
+func (a *Agent) Definitions() []llm.ToolDefinition {
+	var defs []llm.ToolDefinition
+	for _, tool := range a.tools.ListTools() {
+		defs = append(defs, llm.NewToolDefinition(tool.Name(), tool.Description(), tool.Schema()))
+	}
+	return defs
+}
+
+// Run continues the conversation in messages with model, streaming the
+// model's content to onToken. It returns the messages added during the turn:
+// assistant tool requests, tool results and the final assistant reply. On
+// error the messages added so far are returned with it.
+func (a *Agent) Run(ctx context.Context, model string, messages []llm.Message, onToken func(string)) ([]llm.Message, error) {
+	defs := a.Definitions()
+	conversation := append([]llm.Message(nil), messages...)
+	var added []llm.Message
+
+	for step := 0; step < a.MaxSteps; step++ {
+		reply, err := a.client.ChatStream(ctx, model, conversation, defs, onToken)
+		if err != nil {
+			return added, err
+		}
+		conversation = append(conversation, reply)
+		added = append(added, reply)
+
+		if len(reply.ToolCalls) == 0 {
+			return added, nil
+		}
+
+		for _, call := range reply.ToolCalls {
+			if err := ctx.Err(); err != nil {
+				return added, err
+			}
+			result := a.execute(ctx, call)
+			conversation = append(conversation, result)
+			added = append(added, result)
+		}
+	}
+
+	return added, fmt.Errorf("no answer after %d steps; the model kept calling tools", a.MaxSteps)
+}
+
+// execute runs a tool call and wraps its outcome in a tool message. Failures
+// are reported to the model so that it can correct itself.
+func (a *Agent) execute(ctx context.Context, call llm.ToolCall) llm.Message {
+	if a.OnToolCall != nil {
+		a.OnToolCall(call)
+	}
+
+	name := call.Function.Name
+	args := call.Function.Arguments
+	if args == nil {
+		args = make(map[string]interface{})
+	}
+
+	// Failures come back as error results, which the model gets to see
+	result, _ := a.tools.ExecuteContext(ctx, name, args)
+	content := tools.RenderPrompt(result)
+
+	if a.OnToolResult != nil {
+		a.OnToolResult(call, result)
+	}
+	return llm.Message{Role: llm.RoleTool, Content: content, ToolName: name}
+}
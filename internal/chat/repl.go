@@ -0,0 +1,232 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azhany/codecli/internal/checkpoint"
+	"github.com/azhany/codecli/internal/llm"
+	"github.com/azhany/codecli/internal/sandbox"
+)
+
+const prompt = "> "
+
+// REPL reads user messages line by line and prints the streamed replies.
+// Ctrl-C cancels the reply being generated; Ctrl-D or /exit quits.
+type REPL struct {
+	session *Session
+	in      *bufio.Reader
+	out     io.Writer
+	color   bool // Color diffs
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // Cancels the reply in progress, if any
+}
+
+// NewREPL creates a REPL for session reading from in and writing to out
+func NewREPL(session *Session, in io.Reader, out io.Writer) *REPL {
+	r := &REPL{
+		session: session,
+		in:      bufio.NewReader(in),
+		out:     out,
+	}
+	if f, ok := out.(*os.File); ok && isTerminal(f) && os.Getenv("NO_COLOR") == "" {
+		r.color = true
+	}
+	session.Agent().OnToolCall = r.showToolCall
+	return r
+}
+
+// Run reads and answers messages until the input ends or /exit is entered
+func (r *REPL) Run() error {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer func() {
+		signal.Stop(interrupts)
+		close(interrupts)
+	}()
+	go r.handleInterrupts(interrupts)
+
+	fmt.Fprintf(r.out, "Chatting with %s. Type /help for commands, /exit to quit.\n", r.session.Model())
+
+	for {
+		fmt.Fprint(r.out, prompt)
+		line, err := r.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				fmt.Fprintln(r.out)
+				return nil
+			}
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "/") {
+			if quit := r.command(line); quit {
+				return nil
+			}
+			continue
+		}
+
+		r.send(line)
+	}
+}
+
+// handleInterrupts cancels the reply in progress on Ctrl-C. At the prompt it
+// only reminds the user how to quit.
+func (r *REPL) handleInterrupts(interrupts <-chan os.Signal) {
+	for range interrupts {
+		r.mu.Lock()
+		if r.cancel != nil {
+			r.cancel()
+		} else {
+			fmt.Fprint(r.out, "\n(use /exit or Ctrl-D to quit)\n"+prompt)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *REPL) send(message string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.cancel = nil
+		r.mu.Unlock()
+		cancel()
+	}()
+
+	_, err := r.session.Send(ctx, message, func(token string) {
+		fmt.Fprint(r.out, token)
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(r.out, "\n[cancelled]")
+	case err != nil:
+		fmt.Fprintf(r.out, "\nError: %v\n", err)
+	default:
+		fmt.Fprintln(r.out)
+	}
+}
+
+// showToolCall tells the user which tool the model is running
+func (r *REPL) showToolCall(call llm.ToolCall) {
+	args, _ := json.Marshal(call.Function.Arguments)
+	fmt.Fprintf(r.out, "\n[tool: %s %s]\n", call.Function.Name, args)
+}
+
+// ApproveCommand asks the user whether the model may run a command outside
+// the sandbox allow list
+func (r *REPL) ApproveCommand(command, dir string) (sandbox.Approval, error) {
+	fmt.Fprintf(r.out, "\nThe model wants to run in %s:\n  %s\nAllow? [y]es once, [a]lways this session, [N]o: ", dir, command)
+	answer, err := r.in.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(r.out)
+		return sandbox.Reject, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return sandbox.ApproveOnce, nil
+	case "a", "always":
+		return sandbox.ApproveAlways, nil
+	default:
+		return sandbox.Reject, nil
+	}
+}
+
+// command runs a slash-command, reporting whether the REPL should quit
+func (r *REPL) command(line string) bool {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "/exit", "/quit":
+		return true
+
+	case "/help":
+		fmt.Fprint(r.out, `Commands:
+  /clear          Forget the conversation so far
+  /model [name]   Show the current model and those available, or switch to name
+  /save <file>    Save the conversation as JSON
+  /undo           Roll back the file changes of the last turn
+  /exit           Quit (also /quit or Ctrl-D)
+Ctrl-C cancels a reply while it is being generated.
+`)
+
+	case "/clear":
+		r.session.Clear()
+		fmt.Fprintln(r.out, "Conversation cleared")
+
+	case "/model":
+		if len(args) > 0 {
+			r.session.SetModel(args[0])
+			fmt.Fprintf(r.out, "Switched to %s\n", args[0])
+			break
+		}
+		fmt.Fprintf(r.out, "Current model: %s\n", r.session.Model())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		models, err := r.session.client.ListModels(ctx)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(r.out, "Error listing models: %v\n", err)
+			break
+		}
+		fmt.Fprintln(r.out, "Available models:")
+		for _, model := range models {
+			fmt.Fprintf(r.out, "  %s\n", model)
+		}
+
+	case "/save":
+		if len(args) != 1 {
+			fmt.Fprintln(r.out, "Usage: /save <file>")
+			break
+		}
+		if err := r.session.Save(args[0]); err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			break
+		}
+		fmt.Fprintf(r.out, "Saved %d messages to %s\n", len(r.session.History()), args[0])
+
+	case "/undo":
+		store := r.session.Checkpoints()
+		if store == nil {
+			fmt.Fprintln(r.out, "Checkpoints are not enabled")
+			break
+		}
+		restored, err := store.Undo()
+		if errors.Is(err, checkpoint.ErrNoCheckpoints) {
+			fmt.Fprintln(r.out, "Nothing to undo")
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			break
+		}
+		for _, f := range restored.Files {
+			if f.Existed {
+				fmt.Fprintf(r.out, "Restored %s\n", f.Path)
+			} else {
+				fmt.Fprintf(r.out, "Removed %s\n", f.Path)
+			}
+		}
+
+	default:
+		fmt.Fprintf(r.out, "Unknown command %s (type /help for commands)\n", name)
+	}
+	return false
+}
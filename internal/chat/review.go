@@ -0,0 +1,128 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/azhany/codecli/internal/tools"
+)
+
+// ANSI colors for diffs
+const (
+	colorReset = "\033[0m"
+	colorBold  = "\033[1m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+// ReviewChange shows a file change proposed by the model and asks the user
+// to accept it, reject it, edit it first or accept all changes for the rest
+// of the session
+func (r *REPL) ReviewChange(path, diff, content string) (tools.Decision, string, error) {
+	fmt.Fprintf(r.out, "\nThe model wants to change %s:\n%s", path, r.colorDiff(diff))
+
+	for {
+		fmt.Fprint(r.out, "Apply? [y]es, [n]o, [e]dit, [a]ll for this session: ")
+		answer, err := r.in.ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Fprintln(r.out)
+			return tools.RejectChange, content, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return tools.AcceptChange, content, nil
+		case "a", "all":
+			return tools.AcceptAllChanges, content, nil
+		case "e", "edit":
+			edited, err := editContent(path, content)
+			if err != nil {
+				fmt.Fprintf(r.out, "Error: %v\n", err)
+				continue
+			}
+			return tools.AcceptChange, edited, nil
+		case "", "n", "no":
+			return tools.RejectChange, content, nil
+		}
+	}
+}
+
+// colorDiff colors a unified diff if the output is a terminal
+func (r *REPL) colorDiff(diff string) string {
+	if !r.color {
+		return diff
+	}
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		color := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			color = colorBold
+		case strings.HasPrefix(line, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(line, "+"):
+			color = colorGreen
+		case strings.HasPrefix(line, "-"):
+			color = colorRed
+		}
+		if color == "" || line == "" {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(color + strings.TrimSuffix(line, "\n") + colorReset)
+		if strings.HasSuffix(line, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// editContent opens content in the user's editor ($VISUAL, $EDITOR or vi)
+// and returns the saved result
+func editContent(path, content string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// Keep the extension so the editor picks the right syntax
+	tmp, err := os.CreateTemp("", "codecli-*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The editor command may carry arguments, e.g. "code --wait"
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", tmp.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor failed: %v", err)
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,106 @@
+// Package chat implements the interactive conversation with the chat model
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/azhany/codecli/internal/agent"
+	"github.com/azhany/codecli/internal/checkpoint"
+	"github.com/azhany/codecli/internal/config"
+	"github.com/azhany/codecli/internal/llm"
+	"github.com/azhany/codecli/internal/tools"
+)
+
+// Session is a multi-turn conversation with the chat model, which may call
+// the tools registered with the session's tool manager
+type Session struct {
+	client      *llm.Client
+	agent       *agent.Agent
+	model       string
+	history     []llm.Message
+	checkpoints *checkpoint.Store // Each turn's file changes form a checkpoint
+}
+
+// NewSession creates a session using model, or ollama.chat_model if empty
+func NewSession(client *llm.Client, manager *tools.Manager, model string) *Session {
+	if model == "" {
+		model = config.Config.Ollama.ChatModel
+	}
+	return &Session{client: client, agent: agent.New(client, manager), model: model}
+}
+
+// Agent returns the agent running the session's tool calls
+func (s *Session) Agent() *agent.Agent {
+	return s.agent
+}
+
+// Model returns the model the session talks to
+func (s *Session) Model() string {
+	return s.model
+}
+
+// SetModel switches the model used for the following turns
+func (s *Session) SetModel(model string) {
+	s.model = model
+}
+
+// SetCheckpoints makes each turn start a checkpoint in store, so that the
+// files changed during it can be restored together
+func (s *Session) SetCheckpoints(store *checkpoint.Store) {
+	s.checkpoints = store
+}
+
+// Checkpoints returns the session's checkpoint store, if any
+func (s *Session) Checkpoints() *checkpoint.Store {
+	return s.checkpoints
+}
+
+// History returns the messages exchanged so far
+func (s *Session) History() []llm.Message {
+	return s.history
+}
+
+// Clear forgets the conversation history
+func (s *Session) Clear() {
+	s.history = nil
+}
+
+// Send adds a user message to the conversation and streams the model's reply
+// to onToken, running any tools the model calls on the way. A turn that fails
+// or is cancelled is dropped from the history, so the conversation can simply
+// continue with the next message.
+func (s *Session) Send(ctx context.Context, message string, onToken func(string)) (string, error) {
+	messages := append(s.history[:len(s.history):len(s.history)], llm.Message{Role: llm.RoleUser, Content: message})
+	if s.checkpoints != nil {
+		s.checkpoints.Begin(message)
+	}
+
+	added, err := s.agent.Run(ctx, s.model, messages, onToken)
+	if err != nil {
+		return "", err
+	}
+
+	s.history = append(messages, added...)
+	return added[len(added)-1].Content, nil
+}
+
+// transcript is the format written by Save
+type transcript struct {
+	Model    string        `json:"model"`
+	Messages []llm.Message `json:"messages"`
+}
+
+// Save writes the conversation to path as JSON
+func (s *Session) Save(path string) error {
+	data, err := json.MarshalIndent(transcript{Model: s.model, Messages: s.history}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversation: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write conversation: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+// Package checkpoint records the content of files before tools change them,
+// so that a turn's edits can be rolled back
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Dir is where checkpoints are kept, relative to the workspace root
+const Dir = ".codecli/checkpoints"
+
+const manifestFile = "checkpoint.json"
+
+// ErrNoCheckpoints is returned by Undo when there is nothing to undo
+var ErrNoCheckpoints = errors.New("no checkpoints")
+
+// File is the state of one file before a checkpoint's changes
+type File struct {
+	Path    string      `json:"path"` // Relative to the workspace root
+	Existed bool        `json:"existed"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	Blob    string      `json:"blob,omitempty"` // Saved content, in the checkpoint's directory
+}
+
+// Checkpoint groups the changes made during one turn
+type Checkpoint struct {
+	ID          int       `json:"id"`
+	Created     time.Time `json:"created"`
+	Description string    `json:"description,omitempty"`
+	Files       []File    `json:"files"`
+}
+
+// Store keeps checkpoints in a directory below the workspace root. Changes
+// are recorded in the current checkpoint, which Begin starts; without one,
+// the first change starts it.
+type Store struct {
+	root string // Workspace root
+	dir  string
+
+	mu          sync.Mutex
+	current     *Checkpoint
+	description string // For the checkpoint Begin will create
+	pending     bool   // Begin was called; the next change starts a checkpoint
+}
+
+// NewStore creates a store for the workspace at root
+func NewStore(root string) *Store {
+	return &Store{root: root, dir: filepath.Join(root, Dir)}
+}
+
+// Begin starts a new checkpoint for the changes that follow. It is only
+// written once something changes.
+func (s *Store) Begin(description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = nil
+	s.description = description
+	s.pending = true
+}
+
+// Snapshot records the content of the file at path, an absolute path inside
+// the workspace, before it is changed. Only the first snapshot of a file in
+// a checkpoint is kept, as that is the state to return to.
+func (s *Store) Snapshot(path string) error {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %v", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil || s.pending {
+		if err := s.create(); err != nil {
+			return err
+		}
+	}
+	for _, f := range s.current.Files {
+		if f.Path == rel {
+			return nil
+		}
+	}
+
+	file := File{Path: rel}
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to record %s: %v", rel, err)
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to record %s: %v", rel, err)
+		}
+		file.Existed = true
+		file.Mode = info.Mode().Perm()
+		file.Blob = strconv.Itoa(len(s.current.Files))
+		if err := os.WriteFile(filepath.Join(s.checkpointDir(s.current.ID), file.Blob), data, 0600); err != nil {
+			return fmt.Errorf("failed to record %s: %v", rel, err)
+		}
+	}
+
+	s.current.Files = append(s.current.Files, file)
+	return s.save(s.current)
+}
+
+// create starts a new checkpoint numbered after the existing ones
+func (s *Store) create() error {
+	checkpoints, err := s.list()
+	if err != nil {
+		return err
+	}
+	id := 1
+	if len(checkpoints) > 0 {
+		id = checkpoints[len(checkpoints)-1].ID + 1
+	}
+
+	if err := os.MkdirAll(s.checkpointDir(id), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint: %v", err)
+	}
+	s.current = &Checkpoint{ID: id, Created: time.Now(), Description: s.description}
+	s.description = ""
+	s.pending = false
+	return nil
+}
+
+func (s *Store) save(c *Checkpoint) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.checkpointDir(c.ID), manifestFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) checkpointDir(id int) string {
+	return filepath.Join(s.dir, strconv.Itoa(id))
+}
+
+// List returns the checkpoints, oldest first
+func (s *Store) List() ([]*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+func (s *Store) list() ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %v", err)
+	}
+
+	var checkpoints []*Checkpoint
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name(), manifestFile))
+		if os.IsNotExist(err) {
+			continue // Created, but nothing recorded yet
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint %s: %v", entry.Name(), err)
+		}
+		var c Checkpoint
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint %s: %v", entry.Name(), err)
+		}
+		checkpoints = append(checkpoints, &c)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].ID < checkpoints[j].ID })
+	return checkpoints, nil
+}
+
+// Undo rolls back the most recent checkpoint
+func (s *Store) Undo() (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, ErrNoCheckpoints
+	}
+	last := checkpoints[len(checkpoints)-1]
+	return last, s.restore(checkpoints, last.ID)
+}
+
+// Restore returns the workspace to its state before checkpoint id, rolling
+// back that checkpoint and every later one. Rolled back checkpoints are
+// removed. It returns the rolled back checkpoints, newest first.
+func (s *Store) Restore(id int) ([]*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	var rolledBack []*Checkpoint
+	for i := len(checkpoints) - 1; i >= 0 && checkpoints[i].ID >= id; i-- {
+		rolledBack = append(rolledBack, checkpoints[i])
+	}
+	if len(rolledBack) == 0 || rolledBack[len(rolledBack)-1].ID != id {
+		return nil, fmt.Errorf("checkpoint %d not found", id)
+	}
+	return rolledBack, s.restore(checkpoints, id)
+}
+
+// restore rolls back the checkpoints from id on. The oldest recorded state
+// of each file wins. All files are staged before any is replaced, and the
+// replaced files are kept until every one is in place, so a failure leaves
+// the workspace unchanged.
+func (s *Store) restore(checkpoints []*Checkpoint, id int) error {
+	type target struct {
+		file File
+		blob string // Absolute path of the saved content
+	}
+	targets := make(map[string]target)
+	for i := len(checkpoints) - 1; i >= 0 && checkpoints[i].ID >= id; i-- {
+		c := checkpoints[i]
+		for _, f := range c.Files {
+			targets[f.Path] = target{file: f, blob: filepath.Join(s.checkpointDir(c.ID), f.Blob)}
+		}
+	}
+
+	// Stage the restored content next to each file
+	staged := make(map[string]string)
+	defer func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}()
+	for path, t := range targets {
+		if !t.file.Existed {
+			continue
+		}
+		data, err := os.ReadFile(t.blob)
+		if err != nil {
+			return fmt.Errorf("failed to read saved copy of %s: %v", path, err)
+		}
+		abs := filepath.Join(s.root, path)
+		if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", path, err)
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(abs), "."+filepath.Base(abs)+".*.tmp")
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %v", path, err)
+		}
+		staged[path] = tmp.Name()
+		_, err = tmp.Write(data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), t.file.Mode)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %v", path, err)
+		}
+	}
+
+	// Move each current file aside before replacing or removing it, and
+	// put them all back if any step fails
+	type replaced struct {
+		abs    string
+		backup string // Where the current file was moved; "" if there was none
+		placed bool   // The restored content is in place
+	}
+	var done []replaced
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			r := done[i]
+			if r.placed {
+				os.Remove(r.abs)
+			}
+			if r.backup != "" {
+				os.Rename(r.backup, r.abs)
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(targets))
+	for path := range targets {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		abs := filepath.Join(s.root, path)
+		r := replaced{abs: abs}
+		if _, err := os.Lstat(abs); err == nil {
+			backup, err := moveAside(abs)
+			if err != nil {
+				rollback()
+				return fmt.Errorf("failed to restore %s: %v", path, err)
+			}
+			r.backup = backup
+		}
+		done = append(done, r)
+
+		if targets[path].file.Existed {
+			if err := os.Rename(staged[path], abs); err != nil {
+				rollback()
+				return fmt.Errorf("failed to restore %s: %v", path, err)
+			}
+			delete(staged, path)
+			done[len(done)-1].placed = true
+		}
+	}
+	for _, r := range done {
+		if r.backup != "" {
+			os.Remove(r.backup)
+		}
+	}
+
+	for i := len(checkpoints) - 1; i >= 0 && checkpoints[i].ID >= id; i-- {
+		if err := os.RemoveAll(s.checkpointDir(checkpoints[i].ID)); err != nil {
+			return fmt.Errorf("failed to remove checkpoint %d: %v", checkpoints[i].ID, err)
+		}
+	}
+	if s.current != nil && s.current.ID >= id {
+		s.current = nil
+	}
+	return nil
+}
+
+// moveAside renames a file to a new name in its directory and returns that
+// name
+func moveAside(path string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.bak")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	if err := os.Rename(path, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
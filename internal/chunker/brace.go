@@ -0,0 +1,229 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+)
+
+// BraceChunker chunks C-family languages (JavaScript, TypeScript, Java,
+// C/C++, PHP, ...) heuristically by tracking brace depth. Each top-level
+// braced block, together with the comments and annotations directly above
+// it, becomes a chunk; oversized blocks such as classes are split into their
+// members.
+type BraceChunker struct{}
+
+func (BraceChunker) Chunk(content string) []Chunk {
+	lines := strings.Split(content, "\n")
+	return assemble(lines, braceSpans(lines, 0, len(lines), "", 0))
+}
+
+var (
+	containerRe = regexp.MustCompile(`\b(class|interface|struct|enum|trait|namespace|impl|object)\s+([A-Za-z_$][\w$]*)`)
+	functionRe  = regexp.MustCompile(`\bfunction\s*\*?\s*([A-Za-z_$][\w$]*)`)
+	arrowRe     = regexp.MustCompile(`\b(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[A-Za-z_$][\w$]*\s*=>)`)
+	callableRe  = regexp.MustCompile(`([A-Za-z_$~][\w$]*(?:::[A-Za-z_$~][\w$]*)*)\s*(?:<[^<>]*>)?\s*\(`)
+	keywordRe   = regexp.MustCompile(`^(if|for|while|switch|catch|return|new|sizeof|typeof|function|super|this)$`)
+)
+
+const maxNestingDepth = 2
+
+// braceSpans finds the braced blocks at depth zero of lines[start:end].
+// Blocks larger than maxChunkLines are scanned again for members.
+func braceSpans(lines []string, start, end int, container string, nesting int) []span {
+	var (
+		spans       []span
+		scanner     braceScanner
+		depth       int
+		headerStart = -1
+		blockStart  = -1
+	)
+
+	for i := start; i < end; i++ {
+		trimmed := strings.TrimSpace(lines[i])
+
+		if depth == 0 {
+			if trimmed == "" {
+				headerStart = -1
+				continue
+			}
+			if headerStart < 0 {
+				headerStart = i
+			}
+		}
+
+		before := depth
+		depth += scanner.delta(lines[i])
+
+		switch {
+		case before == 0 && depth > 0:
+			blockStart = headerStart
+		case before > 0 && depth <= 0:
+			depth = 0
+			spans = append(spans, braceBlock(lines, blockStart, i+1, container, nesting))
+			headerStart = -1
+		case depth < 0:
+			// A stray closing brace at the top level must not hide the
+			// blocks that follow it
+			depth = 0
+			headerStart = -1
+		case before == 0 && depth == 0 && endsStatement(trimmed):
+			headerStart = -1
+		}
+	}
+
+	// An unterminated block runs to the end of the range
+	if depth > 0 && blockStart >= 0 {
+		spans = append(spans, braceBlock(lines, blockStart, end, container, nesting))
+	}
+
+	return spans
+}
+
+// braceBlock describes a block spanning lines[start:end]
+func braceBlock(lines []string, start, end int, container string, nesting int) span {
+	symbol, kind := describeHeader(lines, start, end, container != "")
+	s := span{start: start, end: end, symbol: qualify(container, symbol), kind: kind}
+
+	// Split large blocks into members when the body sits between an opening
+	// line and a closing line of its own
+	if end-start > maxChunkLines && nesting < maxNestingDepth {
+		open := start
+		for open < end && !strings.Contains(stripLine(lines[open]), "{") {
+			open++
+		}
+		if open+1 < end-1 {
+			s.children = braceSpans(lines, open+1, end-1, s.symbol, nesting+1)
+		}
+	}
+	return s
+}
+
+// describeHeader derives a symbol name and kind from the lines leading up to
+// the opening brace of a block
+func describeHeader(lines []string, start, end int, member bool) (string, string) {
+	var header strings.Builder
+	for i := start; i < end; i++ {
+		line := stripLine(lines[i])
+		if isCommentOrAnnotation(line) {
+			continue
+		}
+		if idx := strings.Index(line, "{"); idx >= 0 {
+			header.WriteString(line[:idx])
+			break
+		}
+		header.WriteString(line)
+		header.WriteByte(' ')
+	}
+	text := header.String()
+
+	if m := containerRe.FindStringSubmatch(text); m != nil {
+		switch m[1] {
+		case "interface", "trait":
+			return m[2], KindInterface
+		case "struct", "enum":
+			return m[2], KindType
+		default:
+			return m[2], KindClass
+		}
+	}
+	if m := functionRe.FindStringSubmatch(text); m != nil {
+		return m[1], functionKind(member)
+	}
+	if m := arrowRe.FindStringSubmatch(text); m != nil {
+		return m[1], functionKind(member)
+	}
+	for _, m := range callableRe.FindAllStringSubmatch(text, -1) {
+		name := m[1]
+		if idx := strings.LastIndex(name, "::"); idx >= 0 {
+			// Out-of-line C++ definition: Class::method
+			return strings.ReplaceAll(name, "::", "."), KindMethod
+		}
+		if !keywordRe.MatchString(name) {
+			return name, functionKind(member)
+		}
+	}
+	return "", KindBlock
+}
+
+func functionKind(member bool) string {
+	if member {
+		return KindMethod
+	}
+	return KindFunction
+}
+
+// endsStatement reports whether a depth-zero line completes a statement that
+// is not followed by a block, e.g. an import or a preprocessor directive
+func endsStatement(trimmed string) bool {
+	return strings.HasSuffix(trimmed, ";") ||
+		strings.HasSuffix(trimmed, "}") ||
+		strings.HasPrefix(trimmed, "#") ||
+		strings.HasPrefix(trimmed, "<?php")
+}
+
+// braceScanner counts braces outside strings and comments across lines
+type braceScanner struct {
+	inBlockComment bool
+	inTemplate     bool // Inside a multi-line JavaScript template literal
+}
+
+func (s *braceScanner) delta(line string) int {
+	delta := 0
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case s.inBlockComment:
+			if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+				s.inBlockComment = false
+				i++
+			}
+		case s.inTemplate:
+			if c == '\\' {
+				i++
+			} else if c == '`' {
+				s.inTemplate = false
+			}
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return delta
+		case c == '/' && i+1 < len(line) && line[i+1] == '*':
+			s.inBlockComment = true
+			i++
+		case c == '#' && strings.TrimSpace(line[:i]) == "" && !strings.HasPrefix(line[i:], "#["):
+			return delta // Preprocessor directive or PHP comment
+		case c == '`':
+			s.inTemplate = true
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			delta++
+		case c == '}':
+			delta--
+		}
+	}
+	return delta
+}
+
+// isCommentOrAnnotation reports whether a header line carries no signature
+func isCommentOrAnnotation(line string) bool {
+	for _, prefix := range []string{"/*", "*", "@", "#"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripLine removes a trailing line comment for header parsing
+func stripLine(line string) string {
+	if idx := strings.Index(line, "//"); idx >= 0 {
+		line = line[:idx]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,162 @@
+// Package chunker splits source files into chunks for embedding, following
+// function and type boundaries where the language allows
+package chunker
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Chunk kinds
+const (
+	KindFunction  = "function"
+	KindMethod    = "method"
+	KindType      = "type"
+	KindClass     = "class"
+	KindInterface = "interface"
+	KindConst     = "const"
+	KindVar       = "var"
+	KindPackage   = "package"
+	KindBlock     = "block"
+	KindLines     = "lines"
+)
+
+const (
+	// maxChunkLines is the largest chunk emitted; bigger declarations are split
+	// into their members or, failing that, into overlapping windows
+	maxChunkLines = 100
+	windowLines   = 50
+	overlapLines  = 5
+)
+
+// Chunk is a contiguous region of a source file
+type Chunk struct {
+	StartLine int // 1-based, inclusive
+	EndLine   int // 1-based, inclusive
+	Content   string
+	Symbol    string // Qualified name of the declaration, e.g. "VectorStore.Search"
+	Kind      string
+}
+
+// Chunker splits file content into chunks
+type Chunker interface {
+	Chunk(content string) []Chunk
+}
+
+// ForFile returns the chunker for a file based on its extension
+func ForFile(path string) Chunker {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".go":
+		return GoChunker{}
+	case ".py":
+		return IndentChunker{}
+	case ".js", ".jsx", ".ts", ".tsx", ".java", ".c", ".h", ".cc", ".cpp", ".hpp", ".cs", ".php", ".kt", ".swift", ".scala":
+		return BraceChunker{}
+	default:
+		return LineChunker{}
+	}
+}
+
+// LineChunker cuts files into fixed-size overlapping line windows
+type LineChunker struct{}
+
+func (LineChunker) Chunk(content string) []Chunk {
+	lines := strings.Split(content, "\n")
+	return windows(lines, 0, len(lines), "", KindLines)
+}
+
+// span is a region of lines [start, end) with its declaration info
+type span struct {
+	start, end int // 0-based, end exclusive
+	symbol     string
+	kind       string
+	children   []span // Member declarations, used to split oversized spans
+}
+
+// windows splits lines[start:end] into overlapping windows, skipping blank ones
+func windows(lines []string, start, end int, symbol, kind string) []Chunk {
+	var chunks []Chunk
+	for i := start; i < end; i += windowLines - overlapLines {
+		stop := i + windowLines
+		if stop > end {
+			stop = end
+		}
+		if chunk, ok := makeChunk(lines, i, stop, symbol, kind); ok {
+			chunks = append(chunks, chunk)
+		}
+		if stop >= end {
+			break
+		}
+	}
+	return chunks
+}
+
+// makeChunk builds a chunk from lines[start:end], reporting false if it is blank
+func makeChunk(lines []string, start, end int, symbol, kind string) (Chunk, bool) {
+	content := strings.Join(lines[start:end], "\n")
+	if strings.TrimSpace(content) == "" {
+		return Chunk{}, false
+	}
+	return Chunk{
+		StartLine: start + 1,
+		EndLine:   end,
+		Content:   content,
+		Symbol:    symbol,
+		Kind:      kind,
+	}, true
+}
+
+// assemble turns declaration spans into chunks, covering the lines between
+// spans with KindLines chunks so that no code goes unindexed
+func assemble(lines []string, spans []span) []Chunk {
+	return assembleRange(lines, 0, len(lines), spans, "", KindLines)
+}
+
+// assembleRange chunks lines[start:end]. Oversized spans are split into
+// their members when known, otherwise into windows; lines between spans are
+// attributed to gapSymbol and gapKind.
+func assembleRange(lines []string, start, end int, spans []span, gapSymbol, gapKind string) []Chunk {
+	var chunks []Chunk
+	pos := start
+	for _, s := range spans {
+		if s.start < pos {
+			s.start = pos // Overlapping spans keep their first owner
+		}
+		if s.end > end {
+			s.end = end
+		}
+		if s.start >= s.end {
+			continue
+		}
+		if s.start > pos {
+			chunks = append(chunks, windows(lines, pos, s.start, gapSymbol, gapKind)...)
+		}
+		switch {
+		case s.end-s.start <= maxChunkLines:
+			if chunk, ok := makeChunk(lines, s.start, s.end, s.symbol, s.kind); ok {
+				chunks = append(chunks, chunk)
+			}
+		case len(s.children) > 0:
+			chunks = append(chunks, assembleRange(lines, s.start, s.end, s.children, s.symbol, s.kind)...)
+		default:
+			chunks = append(chunks, windows(lines, s.start, s.end, s.symbol, s.kind)...)
+		}
+		pos = s.end
+	}
+	if pos < end {
+		chunks = append(chunks, windows(lines, pos, end, gapSymbol, gapKind)...)
+	}
+	return chunks
+}
+
+// qualify joins a container symbol and a member symbol
+func qualify(container, member string) string {
+	switch {
+	case container == "":
+		return member
+	case member == "":
+		return container
+	default:
+		return container + "." + member
+	}
+}
@@ -0,0 +1,133 @@
+package chunker
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// GoChunker chunks Go source along top-level declarations using go/parser.
+// Files that fail to parse fall back to the BraceChunker.
+type GoChunker struct{}
+
+func (GoChunker) Chunk(content string) []Chunk {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return BraceChunker{}.Chunk(content)
+	}
+
+	lines := strings.Split(content, "\n")
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+
+	// Package clause and imports form the file header
+	var spans []span
+	headerStart := line(file.Package)
+	if file.Doc != nil {
+		headerStart = line(file.Doc.Pos())
+	}
+	headerEnd := line(file.Name.End())
+	for _, imp := range file.Imports {
+		if end := line(imp.End()); end > headerEnd {
+			headerEnd = end
+		}
+	}
+
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			if end := line(gen.End()); end > headerEnd {
+				headerEnd = end
+			}
+			continue
+		}
+		spans = append(spans, goDeclSpans(decl, line)...)
+	}
+	spans = append([]span{{start: headerStart - 1, end: headerEnd, symbol: file.Name.Name, kind: KindPackage}}, spans...)
+
+	return assemble(lines, spans)
+}
+
+// goDeclSpans returns the spans of a top-level declaration, including its
+// doc comment. Grouped type declarations yield one span per type.
+func goDeclSpans(decl ast.Decl, line func(token.Pos) int) []span {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		start := d.Pos()
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+		s := span{start: line(start) - 1, end: line(d.End()), symbol: d.Name.Name, kind: KindFunction}
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			s.symbol = qualify(receiverName(d.Recv.List[0].Type), d.Name.Name)
+			s.kind = KindMethod
+		}
+		return []span{s}
+
+	case *ast.GenDecl:
+		kind := KindVar
+		switch d.Tok {
+		case token.TYPE:
+			kind = KindType
+		case token.CONST:
+			kind = KindConst
+		}
+
+		start := d.Pos()
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+
+		if d.Tok == token.TYPE && d.Lparen.IsValid() && len(d.Specs) > 1 {
+			var spans []span
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				specStart := ts.Pos()
+				if ts.Doc != nil {
+					specStart = ts.Doc.Pos()
+				}
+				spans = append(spans, span{start: line(specStart) - 1, end: line(ts.End()), symbol: ts.Name.Name, kind: typeKind(ts)})
+			}
+			return spans
+		}
+
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+				kind = typeKind(s)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+		return []span{{start: line(start) - 1, end: line(d.End()), symbol: strings.Join(names, ", "), kind: kind}}
+	}
+
+	return nil
+}
+
+func typeKind(ts *ast.TypeSpec) string {
+	if _, ok := ts.Type.(*ast.InterfaceType); ok {
+		return KindInterface
+	}
+	return KindType
+}
+
+// receiverName returns the type name of a method receiver, without pointer
+// or type parameters
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+)
+
+// IndentChunker chunks indentation-structured languages (Python). Each
+// top-level def or class, with its decorators and the comments directly
+// above it, becomes a chunk; oversized classes are split into their methods.
+type IndentChunker struct{}
+
+func (IndentChunker) Chunk(content string) []Chunk {
+	lines := strings.Split(content, "\n")
+	return assemble(lines, indentSpans(lines, 0, len(lines), "", 0))
+}
+
+var (
+	pyDefRe   = regexp.MustCompile(`^(?:async\s+)?def\s+([A-Za-z_]\w*)`)
+	pyClassRe = regexp.MustCompile(`^class\s+([A-Za-z_]\w*)`)
+)
+
+// indentSpans finds the def and class blocks of lines[start:end] at the
+// indentation of the first non-blank line
+func indentSpans(lines []string, start, end int, container string, nesting int) []span {
+	base := -1
+	for i := start; i < end; i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			base = indentOf(lines[i])
+			break
+		}
+	}
+	if base < 0 {
+		return nil
+	}
+
+	var (
+		spans      []span
+		current    *span
+		lastCode   = -1 // Last non-blank line that belongs to the current block
+		headerFrom = -1 // First decorator or comment line above the next block
+		inString   string
+	)
+
+	closeCurrent := func() {
+		if current != nil {
+			current.end = lastCode + 1
+			spans = append(spans, indentBlock(lines, *current, container, nesting))
+			current = nil
+		}
+	}
+
+	for i := start; i < end; i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		// Lines inside a triple-quoted string never start or end a block
+		if inString != "" {
+			if strings.Count(line, inString)%2 == 1 {
+				inString = ""
+			}
+			lastCode = i
+			continue
+		}
+		if q := openTripleQuote(line); q != "" {
+			inString = q
+		}
+
+		if trimmed == "" {
+			if current == nil {
+				headerFrom = -1
+			}
+			continue
+		}
+
+		indent := indentOf(line)
+		if indent > base || (current != nil && isContinuation(trimmed)) {
+			lastCode = i
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "@") || strings.HasPrefix(trimmed, "#"):
+			// Decorators and comments attach to the following block
+			closeCurrent()
+			if headerFrom < 0 {
+				headerFrom = i
+			}
+		case pyDefRe.MatchString(trimmed) || pyClassRe.MatchString(trimmed):
+			closeCurrent()
+			blockStart := i
+			if headerFrom >= 0 {
+				blockStart = headerFrom
+			}
+			current = &span{start: blockStart}
+			lastCode = i
+			headerFrom = -1
+		default:
+			closeCurrent()
+			headerFrom = -1
+		}
+	}
+	closeCurrent()
+
+	return spans
+}
+
+// indentBlock names a def/class span and finds its members if it is large
+func indentBlock(lines []string, s span, container string, nesting int) span {
+	for i := s.start; i < s.end; i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if m := pyClassRe.FindStringSubmatch(trimmed); m != nil {
+			s.symbol, s.kind = qualify(container, m[1]), KindClass
+			break
+		}
+		if m := pyDefRe.FindStringSubmatch(trimmed); m != nil {
+			s.symbol, s.kind = qualify(container, m[1]), functionKind(container != "")
+			break
+		}
+	}
+
+	if s.end-s.start > maxChunkLines && nesting < maxNestingDepth {
+		// The body starts after the header line ending in ':'
+		body := s.start
+		for body < s.end && !strings.HasSuffix(strings.TrimSpace(lines[body]), ":") {
+			body++
+		}
+		if body+1 < s.end {
+			s.children = indentSpans(lines, body+1, s.end, s.symbol, nesting+1)
+		}
+	}
+	return s
+}
+
+// isContinuation reports whether a line at block indentation continues the
+// previous statement, e.g. the closing bracket of a multi-line signature
+func isContinuation(trimmed string) bool {
+	return strings.HasPrefix(trimmed, ")") || strings.HasPrefix(trimmed, "]") || strings.HasPrefix(trimmed, "}")
+}
+
+// openTripleQuote returns the delimiter of a triple-quoted string left open
+// at the end of the line
+func openTripleQuote(line string) string {
+	for _, q := range []string{`"""`, `'''`} {
+		if strings.Count(line, q)%2 == 1 {
+			return q
+		}
+	}
+	return ""
+}
+
+func indentOf(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
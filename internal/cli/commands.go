@@ -1,10 +1,18 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/azhany/codecli/internal/agent"
+	"github.com/azhany/codecli/internal/chat"
+	"github.com/azhany/codecli/internal/checkpoint"
+	"github.com/azhany/codecli/internal/config"
+	"github.com/azhany/codecli/internal/llm"
 	"github.com/azhany/codecli/internal/tools"
 	"github.com/azhany/codecli/internal/types"
 	"github.com/azhany/codecli/internal/vector"
@@ -13,24 +21,22 @@ import (
 
 // AddCommands adds all CLI commands to the root command
 func AddCommands(rootCmd *cobra.Command) {
-	// Initialize core components
-	vectorStore, err := vector.NewStore()
-	if err != nil {
-		fmt.Printf("Error initializing vector store: %v\n", err)
-		os.Exit(1)
-	}
-
 	toolManager := tools.NewManager()
 
-	// Register tools
-	searchTool := tools.NewSearch(vectorStore)
-	toolManager.RegisterTool(searchTool)
+	rootCmd.PersistentFlags().String("config", "", "Configuration file to read instead of the user and project files")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(cmd); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
 
 	// Config commands
 	configCmd := &cobra.Command{
 		Use:   "config",
 		Short: "Manage configuration",
 	}
+	addConfigCommands(configCmd)
 	rootCmd.AddCommand(configCmd)
 
 	// Index command
@@ -38,49 +44,88 @@ func AddCommands(rootCmd *cobra.Command) {
 		Use:   "index",
 		Short: "Index codebase for semantic search",
 		Run: func(cmd *cobra.Command, args []string) {
-			tool, err := toolManager.GetTool("search")
+			tool, err := searchTool(toolManager)
 			if err != nil {
-				fmt.Println("Error:", err)
+				fmt.Fprintln(os.Stderr, "Error:", err)
 				os.Exit(1)
 			}
 
-			_, err = tool.Execute(map[string]interface{}{
-				"operation": "index",
+			path, _ := cmd.Flags().GetString("path")
+			workers, _ := cmd.Flags().GetInt("workers")
+			batchSize, _ := cmd.Flags().GetInt("batch-size")
+			rebuild, _ := cmd.Flags().GetBool("rebuild")
+			result, err := tool.Execute(map[string]interface{}{
+				"operation":  "index",
+				"path":       path,
+				"workers":    workers,
+				"batch_size": batchSize,
+				"rebuild":    rebuild,
 			})
 			if err != nil {
-				fmt.Println("Error:", err)
+				fmt.Fprintln(os.Stderr, "Error:", err)
 				os.Exit(1)
 			}
 			fmt.Println("Successfully indexed codebase")
+			if stats, ok := result.Data.(*vector.IndexStats); ok {
+				fmt.Printf("Files: %s\n", stats)
+			}
 		},
 	}
+	indexCmd.Flags().String("path", "", "Directory to index (defaults to workspace.root)")
+	indexCmd.Flags().Int("workers", 0, "Number of concurrent workers (defaults to the number of CPUs)")
+	indexCmd.Flags().Int("batch-size", 0, "Chunks per embedding request (defaults to ngt.batch_size)")
+	bindSetting(indexCmd, "batch-size", "ngt.batch_size")
+	indexCmd.Flags().Bool("rebuild", false, "Discard the existing index and re-embed every file")
 	rootCmd.AddCommand(indexCmd)
 
 	// Search command
 	searchCmd := &cobra.Command{
 		Use:   "search [query]",
 		Short: "Search codebase",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Search the codebase semantically through the index, by keyword or regular
+expression over the workspace files, or both.
+
+Keyword search needs neither an index nor a running Ollama server.`,
+		Args: cobra.ArbitraryArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			tool, err := toolManager.GetTool("search")
+			tool, err := searchTool(toolManager)
 			if err != nil {
-				fmt.Println("Error:", err)
+				fmt.Fprintln(os.Stderr, "Error:", err)
 				os.Exit(1)
 			}
 
-			query := strings.Join(args, " ")
+			query, _ := cmd.Flags().GetString("query")
+			if query == "" {
+				query = strings.Join(args, " ")
+			}
+			if query == "" {
+				fmt.Fprintln(os.Stderr, "Error: a query is required")
+				os.Exit(1)
+			}
+			limit, _ := cmd.Flags().GetInt("limit")
+			searchType, _ := cmd.Flags().GetString("type")
+			path, _ := cmd.Flags().GetString("path")
+			regex, _ := cmd.Flags().GetBool("regex")
+			ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+			context, _ := cmd.Flags().GetInt("context")
 			results, err := tool.Execute(map[string]interface{}{
-				"operation": "search",
-				"query":     query,
+				"operation":   "search",
+				"query":       query,
+				"limit":       limit,
+				"type":        searchType,
+				"path":        path,
+				"regex":       regex,
+				"ignore_case": ignoreCase,
+				"context":     context,
 			})
 			if err != nil {
-				fmt.Println("Error:", err)
+				fmt.Fprintln(os.Stderr, "Error:", err)
 				os.Exit(1)
 			}
 
-			searchResults, ok := results.([]types.SearchResult)
+			searchResults, ok := results.Data.([]types.SearchResult)
 			if !ok {
-				fmt.Println("Error: Invalid search results")
+				fmt.Fprintln(os.Stderr, "Error: Invalid search results")
 				os.Exit(1)
 			}
 
@@ -89,20 +134,263 @@ func AddCommands(rootCmd *cobra.Command) {
 				return
 			}
 
-			for _, result := range searchResults {
-				fmt.Printf("%s:%d: %s\n", result.Path, result.Line, result.Content)
-			}
+			printSearchResults(searchResults)
 		},
 	}
+	searchCmd.Flags().StringP("query", "q", "", "Search query (alternatively given as arguments)")
+	searchCmd.Flags().StringP("type", "t", "semantic", "Search type: keyword, semantic or both")
+	searchCmd.Flags().Int("limit", 10, "Maximum number of results")
+	searchCmd.Flags().String("path", "", "Directory for keyword search (defaults to workspace.root)")
+	searchCmd.Flags().BoolP("regex", "e", false, "Treat the query as a regular expression (keyword search)")
+	searchCmd.Flags().BoolP("ignore-case", "i", false, "Match case-insensitively (keyword search)")
+	searchCmd.Flags().IntP("context", "C", 0, "Lines of context around each match (keyword search)")
 	rootCmd.AddCommand(searchCmd)
 
-	// Chat command (placeholder - will be implemented with chat tool)
+	// Chat command
 	chatCmd := &cobra.Command{
 		Use:   "chat",
 		Short: "Start interactive chat mode",
+		Long: `Start an interactive conversation with the chat model. Replies stream as
+they are generated; Ctrl-C cancels the current reply and /exit or Ctrl-D
+quits. Type /help in the session for the available commands.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Chat functionality will be implemented soon")
+			maxSteps, _ := cmd.Flags().GetInt("max-steps")
+			if maxSteps < 1 {
+				usageError(cmd, "--max-steps must be at least 1")
+			}
+			client, err := llm.NewClient()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+
+			if _, err := searchTool(toolManager); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+
+			model, _ := cmd.Flags().GetString("model")
+			session := chat.NewSession(client, toolManager, model)
+			session.Agent().MaxSteps = maxSteps
+			session.SetCheckpoints(checkpointStore(toolManager))
+			repl := chat.NewREPL(session, os.Stdin, os.Stdout)
+			if tool, err := toolManager.GetTool("command"); err == nil {
+				// Commands outside sandbox.allow need the user's approval
+				tool.(*tools.Command).SetApprover(repl)
+			}
+			yes, _ := cmd.Flags().GetBool("yes")
+			if tool, err := toolManager.GetTool("file"); err == nil && config.Config.Sandbox.ConfirmWrites && !yes {
+				// File changes are shown as diffs to accept, reject or edit
+				tool.(*tools.File).SetReviewer(repl)
+			}
+			if err := repl.Run(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
 		},
 	}
+	chatCmd.Flags().String("model", "", "Chat model to use (defaults to ollama.chat_model)")
+	bindSetting(chatCmd, "model", "ollama.chat_model")
+	chatCmd.Flags().Int("max-steps", agent.DefaultMaxSteps, "Maximum model requests per message while the model calls tools")
+	chatCmd.Flags().BoolP("yes", "y", false, "Apply file changes without asking (overrides sandbox.confirm_writes)")
 	rootCmd.AddCommand(chatCmd)
+
+	// Undo command
+	undoCmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Roll back the file changes of the last turn",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			restored, err := checkpointStore(toolManager).Undo()
+			if errors.Is(err, checkpoint.ErrNoCheckpoints) {
+				fmt.Println("Nothing to undo")
+				return
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Restored %s from checkpoint %d\n", countFiles(len(restored.Files)), restored.ID)
+		},
+	}
+	rootCmd.AddCommand(undoCmd)
+
+	// Checkpoints commands
+	checkpointsCmd := &cobra.Command{
+		Use:   "checkpoints",
+		Short: "List and restore checkpoints of file changes",
+		Long: `Every change the tools make to a file is recorded in a checkpoint under
+.codecli/checkpoints, one checkpoint per chat turn, so that the files can be
+put back as they were.`,
+	}
+	checkpointsCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List checkpoints, oldest first",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			checkpoints, err := checkpointStore(toolManager).List()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			if len(checkpoints) == 0 {
+				fmt.Println("No checkpoints")
+				return
+			}
+			printCheckpoints(checkpoints)
+		},
+	})
+	checkpointsCmd.AddCommand(&cobra.Command{
+		Use:   "restore <id>",
+		Short: "Restore files to their state before checkpoint id, rolling back it and every later checkpoint",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid checkpoint id %q\n", args[0])
+				os.Exit(1)
+			}
+			restored, err := checkpointStore(toolManager).Restore(id)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			for _, c := range restored {
+				fmt.Printf("Rolled back checkpoint %d (%s)\n", c.ID, countFiles(len(c.Files)))
+			}
+		},
+	})
+	rootCmd.AddCommand(checkpointsCmd)
+
+	addToolCommands(rootCmd, toolManager)
+	addCompleteCommand(rootCmd, toolManager)
+}
+
+// searchTool returns the search tool, registering it on first use. The
+// vector store behind it creates the index directory, so only the commands
+// that search or index ask for it, once the configuration is loaded.
+func searchTool(toolManager *tools.Manager) (*tools.Search, error) {
+	if tool, err := toolManager.GetTool("search"); err == nil {
+		return tool.(*tools.Search), nil
+	}
+	vectorStore, err := vector.NewVectorStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize vector store: %v", err)
+	}
+	tool := tools.NewSearch(vectorStore)
+	toolManager.RegisterTool(tool)
+	return tool, nil
+}
+
+// checkpointStore returns the file tool's checkpoint store, exiting if the
+// workspace cannot be set up
+func checkpointStore(toolManager *tools.Manager) *checkpoint.Store {
+	tool, err := toolManager.GetTool("file")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	store, err := tool.(*tools.File).Checkpoints()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+func countFiles(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
+
+// printCheckpoints lists checkpoints with the files each one changed
+func printCheckpoints(checkpoints []*checkpoint.Checkpoint) {
+	for _, c := range checkpoints {
+		description := strings.Join(strings.Fields(c.Description), " ")
+		if len(description) > 60 {
+			description = description[:57] + "..."
+		}
+		fmt.Printf("%4d  %s  %s  %s\n", c.ID, c.Created.Format("2006-01-02 15:04:05"), countFiles(len(c.Files)), description)
+		for _, f := range c.Files {
+			state := "modified"
+			if !f.Existed {
+				state = "created"
+			}
+			fmt.Printf("        %-8s %s\n", state, f.Path)
+		}
+	}
+}
+
+// printSearchResults prints results grep-style. Keyword matches are printed
+// as "path:line:text" and context lines as "path-line-text"; context shared
+// by nearby matches is printed once and separate groups are divided by "--".
+func printSearchResults(results []types.SearchResult) {
+	type outputLine struct {
+		text  string
+		match bool
+	}
+
+	var (
+		path    string
+		lines   map[int]outputLine
+		printed bool
+	)
+	flush := func() {
+		nums := make([]int, 0, len(lines))
+		for n := range lines {
+			nums = append(nums, n)
+		}
+		sort.Ints(nums)
+		for i, n := range nums {
+			if printed && (i == 0 || n > nums[i-1]+1) {
+				fmt.Println("--")
+			}
+			if l := lines[n]; l.match {
+				fmt.Printf("%s:%d:%s\n", path, n, l.text)
+			} else {
+				fmt.Printf("%s-%d-%s\n", path, n, l.text)
+			}
+			printed = true
+		}
+		lines = nil
+	}
+
+	for _, result := range results {
+		if result.Symbol != "" {
+			fmt.Printf("%s:%d: [%s %s]%s\n%s\n", result.Path, result.Line, result.Kind, result.Symbol, scoreSummary(result), result.Content)
+			continue
+		}
+		if result.Before == nil && result.After == nil {
+			fmt.Printf("%s:%d:%s %s\n", result.Path, result.Line, scoreSummary(result), result.Content)
+			continue
+		}
+
+		if result.Path != path {
+			flush()
+			path, lines = result.Path, make(map[int]outputLine)
+		}
+		first := result.Line - len(result.Before)
+		for i, text := range result.Before {
+			if _, ok := lines[first+i]; !ok {
+				lines[first+i] = outputLine{text: text}
+			}
+		}
+		lines[result.Line] = outputLine{text: result.Content, match: true}
+		for i, text := range result.After {
+			if _, ok := lines[result.Line+1+i]; !ok {
+				lines[result.Line+1+i] = outputLine{text: text}
+			}
+		}
+	}
+	flush()
+}
+
+// scoreSummary describes the component scores of a hybrid search result
+func scoreSummary(result types.SearchResult) string {
+	if result.Score == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (score %.4f: semantic %.3f, lexical %.3f)", result.Score, result.SemanticScore, result.LexicalScore)
 }
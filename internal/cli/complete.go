@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/azhany/codecli/internal/completion"
+	"github.com/azhany/codecli/internal/llm"
+	"github.com/azhany/codecli/internal/search"
+	"github.com/azhany/codecli/internal/tools"
+	"github.com/azhany/codecli/internal/types"
+	"github.com/azhany/codecli/internal/vector"
+	"github.com/spf13/cobra"
+)
+
+// addCompleteCommand adds the command that completes code with the code
+// model
+func addCompleteCommand(rootCmd *cobra.Command, toolManager *tools.Manager) {
+	completeCmd := &cobra.Command{
+		Use:   "complete",
+		Short: "Complete code at a cursor position with the code model",
+		Long: `Complete the code at a cursor position in a file with ollama.code_model.
+The model fills in the middle between the code before and after the cursor,
+and sees related code retrieved from the search index if one has been built.
+The cursor defaults to the end of --line, or to the end of the file without
+--line. The completion is printed, or spliced into the file with --insert,
+which records the change in a checkpoint like any other edit.
+
+The code model must support fill-in-the-middle, as codellama, starcoder2
+and qwen2.5-coder do.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			file, _ := cmd.Flags().GetString("file")
+			if file == "" {
+				usageError(cmd, "--file is required")
+			}
+			line, _ := cmd.Flags().GetInt("line")
+			column, _ := cmd.Flags().GetInt("column")
+			if line < 0 || column < 0 {
+				usageError(cmd, "--line and --column count from 1")
+			}
+			if column > 0 && line == 0 {
+				usageError(cmd, "--column requires --line")
+			}
+			contextLines, _ := cmd.Flags().GetInt("context")
+			maxTokens, _ := cmd.Flags().GetInt("max-tokens")
+			related, _ := cmd.Flags().GetInt("related")
+			stops, _ := cmd.Flags().GetStringArray("stop")
+			model, _ := cmd.Flags().GetString("model")
+			insert, _ := cmd.Flags().GetBool("insert")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			// Read through the file tool, which keeps to the workspace
+			read, err := toolManager.Execute("file", map[string]interface{}{
+				"operation": "read",
+				"path":      file,
+			})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(exitCode(read, err))
+			}
+			content := read.Content[0].Text
+			if _, _, _, err := completion.Cursor(content, line, column); err != nil {
+				usageError(cmd, err.Error())
+			}
+
+			client, err := llm.NewClient()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(exitFailure)
+			}
+			var retriever search.Engine
+			if related > 0 {
+				retriever = loadRetriever(toolManager)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			result, err := completion.New(client, retriever).Complete(ctx, completion.Request{
+				Path:         file,
+				Content:      content,
+				Line:         line,
+				Column:       column,
+				ContextLines: contextLines,
+				MaxTokens:    maxTokens,
+				Related:      related,
+				Stop:         stops,
+				Model:        model,
+			})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				if errors.Is(err, context.Canceled) {
+					os.Exit(exitInterrupted)
+				}
+				os.Exit(exitFailure)
+			}
+			if result.RetrievalError != "" {
+				fmt.Fprintln(os.Stderr, "Warning: completing without related code:", result.RetrievalError)
+			}
+
+			if insert && strings.TrimSpace(result.Completion) != "" {
+				edit, err := spliceCompletion(toolManager, file, content, result)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+					os.Exit(exitCode(edit, err))
+				}
+				if !jsonOutput {
+					fmt.Println(edit.Content[0].Text)
+				}
+			}
+
+			switch {
+			case jsonOutput:
+				data, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+					os.Exit(exitFailure)
+				}
+				fmt.Println(string(data))
+			case strings.TrimSpace(result.Completion) == "":
+				fmt.Fprintln(os.Stderr, "The model had no completion")
+			case !insert:
+				fmt.Print(result.Completion)
+				if !strings.HasSuffix(result.Completion, "\n") {
+					fmt.Println()
+				}
+			}
+			if result.Truncated && !jsonOutput {
+				fmt.Fprintf(os.Stderr, "Note: the completion was cut off at %d tokens (--max-tokens)\n", maxTokens)
+			}
+		},
+	}
+	completeCmd.Flags().StringP("file", "f", "", "File to complete, relative to workspace.root")
+	completeCmd.Flags().IntP("line", "l", 0, "Cursor line, counting from 1 (defaults to the end of the file)")
+	completeCmd.Flags().Int("column", 0, "Cursor column in characters, counting from 1 (defaults to the end of the line)")
+	completeCmd.Flags().Int("context", completion.DefaultContextLines, "Lines of code before and after the cursor to show the model; 0 shows the whole file")
+	completeCmd.Flags().Int("max-tokens", completion.DefaultMaxTokens, "Maximum tokens to generate")
+	completeCmd.Flags().Int("related", completion.DefaultRelated, "Related chunks from the search index to show the model; 0 disables retrieval")
+	completeCmd.Flags().StringArray("stop", nil, "Extra stop sequence, in addition to the language's (repeatable)")
+	completeCmd.Flags().String("model", "", "Code model to use (defaults to ollama.code_model)")
+	bindSetting(completeCmd, "model", "ollama.code_model")
+	completeCmd.Flags().Bool("insert", false, "Splice the completion into the file at the cursor")
+	completeCmd.Flags().Bool("json", false, "Print the completion, cursor position and related chunks as JSON")
+	rootCmd.AddCommand(completeCmd)
+}
+
+// loadRetriever loads the search index for finding related code. Without
+// an index, completion goes ahead without related code.
+func loadRetriever(toolManager *tools.Manager) search.Engine {
+	tool, err := searchTool(toolManager)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: completing without related code:", err)
+		return nil
+	}
+	vectorStore := tool.Store()
+	if err := vectorStore.LoadIndex(); err != nil {
+		if !errors.Is(err, vector.ErrIndexNotFound) {
+			fmt.Fprintln(os.Stderr, "Warning: completing without related code: failed to load index:", err)
+		}
+		return nil
+	}
+	return vectorStore
+}
+
+// spliceCompletion inserts a completion into file at its cursor through the
+// file tool
+func spliceCompletion(toolManager *tools.Manager, file, content string, result *completion.Result) (*types.ToolResult, error) {
+	checkpointStore(toolManager).Begin("codecli complete " + file)
+
+	if result.Offset == len(content) {
+		return toolManager.Execute("file", map[string]interface{}{
+			"operation": "append",
+			"path":      file,
+			"content":   result.Completion,
+		})
+	}
+
+	// Replace the cursor line with itself plus the completion
+	start := strings.LastIndexByte(content[:result.Offset], '\n') + 1
+	end := len(content)
+	if idx := strings.IndexByte(content[result.Offset:], '\n'); idx >= 0 {
+		end = result.Offset + idx
+	}
+	return toolManager.Execute("file", map[string]interface{}{
+		"operation":  "replace_lines",
+		"path":       file,
+		"start_line": result.Line,
+		"end_line":   result.Line,
+		"content":    content[start:result.Offset] + result.Completion + content[result.Offset:end],
+	})
+}
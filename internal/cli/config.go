@@ -0,0 +1,347 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/azhany/codecli/internal/config"
+	"github.com/azhany/codecli/internal/llm"
+	"github.com/azhany/codecli/internal/sandbox"
+	"github.com/azhany/codecli/internal/vector"
+	"github.com/azhany/codecli/internal/workspace"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// settingAnnotation marks flags that override a setting; its value is the
+// key of the setting
+const settingAnnotation = "codecli_setting"
+
+// bindSetting makes a flag of cmd override a setting when it is given
+func bindSetting(cmd *cobra.Command, flag, key string) {
+	cmd.Flags().SetAnnotation(flag, settingAnnotation, []string{key})
+}
+
+// loadConfig loads the configuration for cmd, from the file given with
+// --config if any, and applies the flags of cmd bound to settings
+func loadConfig(cmd *cobra.Command) error {
+	file, _ := cmd.Flags().GetString("config")
+	if err := config.LoadConfig(file); err != nil {
+		return err
+	}
+
+	var err error
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if keys := flag.Annotations[settingAnnotation]; len(keys) > 0 && err == nil {
+			err = config.Override(keys[0], flag.Value.String(), "--"+flag.Name)
+		}
+	})
+	return err
+}
+
+// addConfigCommands adds the subcommands of the config command
+func addConfigCommands(configCmd *cobra.Command) {
+	// The config commands don't need the vector store the root command sets
+	// up, only the configuration
+	configCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(cmd); err != nil && cmd.Name() != "init" {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitFailure)
+		}
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Show the effective configuration and where each value comes from",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			switch files := config.FilesUsed(); len(files) {
+			case 0:
+				fmt.Print("Configuration files: none, using the defaults\n\n")
+			default:
+				// Highest precedence first, as the sources are read
+				fmt.Println("Configuration files, highest precedence first:")
+				for i := len(files) - 1; i >= 0; i-- {
+					fmt.Println("  " + files[i])
+				}
+				fmt.Println()
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tSOURCE\tVALUE")
+			for _, key := range config.Keys() {
+				value, _ := config.Value(key)
+				fmt.Fprintf(w, "%s\t%s\t%s\n", key, config.Source(key), config.FormatValue(value))
+			}
+			w.Flush()
+		},
+	})
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration for invalid values and missing models",
+		Long: `Check the effective configuration: URLs, durations, index parameters,
+glob patterns and paths, and that the configured models are available on the
+Ollama server. Exits with status 1 if any problem is found.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			problems := validateConfig()
+			if offline, _ := cmd.Flags().GetBool("offline"); !offline {
+				problems = append(problems, checkModels()...)
+			}
+
+			if len(problems) == 0 {
+				fmt.Println("Configuration is valid")
+				return
+			}
+			for _, problem := range problems {
+				fmt.Println("Invalid", problem)
+			}
+			fmt.Printf("\n%d problem(s) found\n", len(problems))
+			os.Exit(exitFailure)
+		},
+	}
+	validateCmd.Flags().Bool("offline", false, "Skip the checks that need the Ollama server")
+	configCmd.AddCommand(validateCmd)
+
+	setCmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a value in the configuration file",
+		Long: `Set a value in a configuration file, keeping the rest of the file and its
+comments. The file is the one given with --file, the user configuration file
+with --user, or else the configuration file in use with the highest
+precedence, or .codecli/config.yaml if there is none. Lists are given
+separated by commas, e.g.
+
+  codecli config set workspace.exclude_patterns "node_modules,*.git*,build"`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			key, text := strings.ToLower(args[0]), args[1]
+			value, err := config.ParseValue(key, text)
+			if err != nil {
+				usageError(cmd, err.Error())
+			}
+
+			file, _ := cmd.Flags().GetString("file")
+			if user, _ := cmd.Flags().GetBool("user"); user && file == "" {
+				file = userFile(cmd)
+			}
+			if file == "" {
+				file = config.FileUsed()
+			}
+			if file == "" {
+				file = config.ProjectFile
+			}
+			if err := config.SetInFile(file, key, value); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(exitFailure)
+			}
+			fmt.Printf("Set %s = %s in %s\n", key, config.FormatValue(value), file)
+		},
+	}
+	setCmd.Flags().String("file", "", "Configuration file to change (defaults to the one in use)")
+	setCmd.Flags().Bool("user", false, "Change the user configuration file")
+	configCmd.AddCommand(setCmd)
+
+	initCmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "Write a starter configuration file with the defaults",
+		Long: `Write a commented configuration file holding the default settings: the
+project file .codecli/config.yaml, the user configuration file with --user,
+or the given path.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := config.ProjectFile
+			if user, _ := cmd.Flags().GetBool("user"); user {
+				path = userFile(cmd)
+			}
+			if len(args) > 0 {
+				path = args[0]
+			}
+			force, _ := cmd.Flags().GetBool("force")
+			if err := config.WriteStarter(path, force); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v (use --force to replace it)\n", err)
+				os.Exit(exitFailure)
+			}
+			fmt.Println("Wrote", path)
+		},
+	}
+	initCmd.Flags().Bool("force", false, "Replace an existing file")
+	initCmd.Flags().Bool("user", false, "Write the user configuration file, $HOME/.config/codecli/config.yaml")
+	configCmd.AddCommand(initCmd)
+}
+
+// userFile returns the user configuration file, exiting if there is no home
+// directory to hold it
+func userFile(cmd *cobra.Command) string {
+	file := config.UserFile()
+	if file == "" {
+		usageError(cmd, "--user needs a home directory; give the file instead")
+	}
+	return file
+}
+
+// validateConfig checks the settings that can be checked without the
+// Ollama server
+func validateConfig() []string {
+	cfg := config.Config
+	var problems []string
+	add := func(key, format string, args ...interface{}) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	if u, err := url.Parse(cfg.Ollama.URL); err != nil {
+		add("ollama.url", "invalid URL: %v", err)
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("ollama.url", "%q is not an http(s) URL with a host", cfg.Ollama.URL)
+	}
+	for _, d := range []struct{ key, value string }{
+		{"ollama.timeout", cfg.Ollama.Timeout},
+		{"sandbox.timeout", cfg.Sandbox.Timeout},
+	} {
+		if duration, err := time.ParseDuration(d.value); err != nil {
+			add(d.key, "invalid duration %q, e.g. 30s or 2m", d.value)
+		} else if duration <= 0 {
+			add(d.key, "must be positive, got %s", d.value)
+		}
+	}
+	for _, model := range []struct{ key, name string }{
+		{"ollama.chat_model", cfg.Ollama.ChatModel},
+		{"ollama.code_model", cfg.Ollama.CodeModel},
+		{"ollama.embedding_model", cfg.Ollama.EmbeddingModel},
+	} {
+		if model.name == "" {
+			add(model.key, "must not be empty")
+		}
+	}
+
+	for _, n := range []struct {
+		key   string
+		value int
+	}{
+		{"ngt.dimension", cfg.NGT.Dimension},
+		{"ngt.edge_size", cfg.NGT.EdgeSize},
+		{"ngt.ef_construction", cfg.NGT.EfConstruction},
+		{"ngt.ef_search", cfg.NGT.EfSearch},
+		{"ngt.batch_size", cfg.NGT.BatchSize},
+		{"search.rrf_k", cfg.Search.RRFK},
+	} {
+		if n.value <= 0 {
+			add(n.key, "must be greater than 0, got %d", n.value)
+		}
+	}
+	if cfg.NGT.IndexType != vector.IndexTypeHNSW && cfg.NGT.IndexType != vector.IndexTypeFlat {
+		add("ngt.index_type", "must be %s or %s, got %q", vector.IndexTypeHNSW, vector.IndexTypeFlat, cfg.NGT.IndexType)
+	}
+	if _, err := vector.ParseVectorEncoding(cfg.NGT.Quantization); err != nil {
+		add("ngt.quantization", "must be none, float16 or int8, got %q", cfg.NGT.Quantization)
+	}
+
+	for _, dir := range append([]string{cfg.Workspace.Root}, cfg.Workspace.AllowedRoots...) {
+		key := "workspace.root"
+		if dir != cfg.Workspace.Root {
+			key = "workspace.allowed_roots"
+		}
+		if info, err := os.Stat(dir); err != nil {
+			add(key, "%v", err)
+		} else if !info.IsDir() {
+			add(key, "%s is not a directory", dir)
+		}
+	}
+	for _, p := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"workspace.exclude_patterns", cfg.Workspace.ExcludePatterns},
+		{"workspace.protected_paths", cfg.Workspace.ProtectedPaths},
+	} {
+		for _, pattern := range p.patterns {
+			if _, err := workspace.CompilePattern(pattern); err != nil {
+				add(p.key, "%v", err)
+			}
+		}
+	}
+	for _, ext := range cfg.Workspace.IncludeExtensions {
+		if !strings.HasPrefix(ext, ".") {
+			add("workspace.include_extensions", "%q should start with a dot, e.g. .go", ext)
+		}
+	}
+	if cfg.Workspace.MaxFileSize < 0 {
+		add("workspace.max_file_size", "must not be negative")
+	}
+
+	if cfg.Search.SemanticWeight < 0 || cfg.Search.LexicalWeight < 0 {
+		add("search", "semantic_weight and lexical_weight must not be negative")
+	}
+
+	// The sandbox policy compiles the command patterns; sandbox.timeout is
+	// checked above
+	if _, err := sandbox.NewPolicy(); err != nil && !errors.Is(err, sandbox.ErrInvalidTimeout) {
+		add("sandbox", "%v", err)
+	}
+	for _, n := range []struct {
+		key   string
+		value int
+	}{
+		{"sandbox.max_cpu_seconds", cfg.Sandbox.MaxCPUSeconds},
+		{"sandbox.max_memory_mb", cfg.Sandbox.MaxMemoryMB},
+		{"sandbox.max_file_size_mb", cfg.Sandbox.MaxFileSizeMB},
+		{"sandbox.max_output_bytes", cfg.Sandbox.MaxOutputBytes},
+	} {
+		if n.value < 0 {
+			add(n.key, "must not be negative, got %d", n.value)
+		}
+	}
+
+	return problems
+}
+
+// checkModels checks that the configured models are available on the
+// Ollama server
+func checkModels() []string {
+	cfg := config.Config.Ollama
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := llm.NewClient()
+	if err != nil {
+		return []string{"ollama.url: " + err.Error()}
+	}
+	available, err := client.ListModels(ctx)
+	if err != nil {
+		return []string{fmt.Sprintf("ollama.url: cannot list the models on %s: %v", cfg.URL, err)}
+	}
+
+	var problems []string
+	for _, model := range []struct{ key, name string }{
+		{"ollama.chat_model", cfg.ChatModel},
+		{"ollama.code_model", cfg.CodeModel},
+		{"ollama.embedding_model", cfg.EmbeddingModel},
+	} {
+		if model.name != "" && !hasModel(available, model.name) {
+			problems = append(problems, fmt.Sprintf("%s: model %q is not available on %s (run `ollama pull %s`)", model.key, model.name, cfg.URL, model.name))
+		}
+	}
+	return problems
+}
+
+// hasModel reports whether a model is in a list of Ollama model names,
+// treating a missing tag as ":latest"
+func hasModel(available []string, name string) bool {
+	for _, a := range available {
+		if strings.TrimSuffix(a, ":latest") == strings.TrimSuffix(name, ":latest") {
+			return true
+		}
+	}
+	return false
+}
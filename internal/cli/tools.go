@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/azhany/codecli/internal/sandbox"
+	"github.com/azhany/codecli/internal/tools"
+	"github.com/azhany/codecli/internal/types"
+	"github.com/azhany/codecli/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the read, write, list and run commands. run otherwise exits
+// with the status of the command it ran.
+const (
+	exitFailure     = 1   // The operation failed
+	exitUsage       = 2   // Missing or invalid flags
+	exitTimeout     = 124 // The command timed out, as with timeout(1)
+	exitDenied      = 126 // Refused by the sandbox or the workspace confinement
+	exitInterrupted = 130 // Cancelled with Ctrl-C
+)
+
+// addToolCommands adds the commands that call the file and command tools
+// directly
+func addToolCommands(rootCmd *cobra.Command, toolManager *tools.Manager) {
+	// Read command
+	readCmd := &cobra.Command{
+		Use:   "read",
+		Short: "Print a file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			file, _ := cmd.Flags().GetString("file")
+			if file == "" {
+				usageError(cmd, "--file is required")
+			}
+
+			result, err := toolManager.Execute("file", map[string]interface{}{
+				"operation": "read",
+				"path":      file,
+			})
+			finish(cmd, result, err, func() {
+				fmt.Print(result.Text())
+			})
+		},
+	}
+	readCmd.Flags().StringP("file", "f", "", "File to print, relative to workspace.root")
+	addJSONFlag(readCmd)
+	rootCmd.AddCommand(readCmd)
+
+	// Write command
+	writeCmd := &cobra.Command{
+		Use:   "write",
+		Short: "Write or append to a file",
+		Long: `Write content to a file, replacing it unless --append is given. Without
+--content, the content is read from standard input. The previous content is
+saved in a checkpoint, so the change can be rolled back with "codecli undo".`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			file, _ := cmd.Flags().GetString("file")
+			if file == "" {
+				usageError(cmd, "--file is required")
+			}
+			content, _ := cmd.Flags().GetString("content")
+			if !cmd.Flags().Changed("content") {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Error: failed to read standard input:", err)
+					os.Exit(exitFailure)
+				}
+				content = string(data)
+			}
+
+			operation := "write"
+			if appendContent, _ := cmd.Flags().GetBool("append"); appendContent {
+				operation = "append"
+			}
+			checkpointStore(toolManager).Begin(fmt.Sprintf("codecli %s %s", operation, file))
+			result, err := toolManager.Execute("file", map[string]interface{}{
+				"operation": operation,
+				"path":      file,
+				"content":   content,
+			})
+			finish(cmd, result, err, func() {
+				// Only the summary; the diff of a new file repeats it
+				fmt.Println(result.Content[0].Text)
+			})
+		},
+	}
+	writeCmd.Flags().StringP("file", "f", "", "File to write, relative to workspace.root")
+	writeCmd.Flags().StringP("content", "c", "", "Content to write (default: standard input)")
+	writeCmd.Flags().Bool("append", false, "Append to the file instead of replacing it")
+	addJSONFlag(writeCmd)
+	rootCmd.AddCommand(writeCmd)
+
+	// List command
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List files or code definitions in the workspace",
+		Long: `List the files below a directory, or with --type definitions the functions,
+methods, types, classes and constants defined in a file (--file) or in the
+files below a directory (--path). Go is parsed exactly; Python, JavaScript,
+TypeScript, Java, C/C++ and PHP are parsed heuristically.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			listType, _ := cmd.Flags().GetString("type")
+			path, _ := cmd.Flags().GetString("path")
+			file, _ := cmd.Flags().GetString("file")
+			pattern, _ := cmd.Flags().GetString("pattern")
+
+			switch listType {
+			case "files":
+				if file != "" {
+					usageError(cmd, "--file only applies to --type definitions; use --path")
+				}
+			case "definitions":
+				if file != "" && path != "" {
+					usageError(cmd, "--file and --path cannot be used together")
+				}
+				if file != "" {
+					path = file
+				}
+				toolArgs := map[string]interface{}{
+					"path":    path,
+					"pattern": pattern,
+				}
+				if language, _ := cmd.Flags().GetString("language"); language != "" {
+					toolArgs["language"] = language
+				}
+				result, err := toolManager.Execute("definitions", toolArgs)
+				finish(cmd, result, err, func() {
+					fmt.Println(result.Text())
+				})
+				return
+			default:
+				usageError(cmd, fmt.Sprintf("invalid --type %q (want files or definitions)", listType))
+			}
+
+			result, err := toolManager.Execute("file", map[string]interface{}{
+				"operation": "list",
+				"path":      path,
+				"pattern":   pattern,
+			})
+			finish(cmd, result, err, func() {
+				files, _ := result.Data.([]string)
+				for _, file := range files {
+					fmt.Println(file)
+				}
+			})
+		},
+	}
+	listCmd.Flags().StringP("type", "t", "files", "What to list: files or definitions")
+	listCmd.Flags().String("path", "", "Directory to list (defaults to workspace.root)")
+	listCmd.Flags().StringP("file", "f", "", "File whose definitions to list (--type definitions)")
+	listCmd.Flags().StringP("pattern", "p", "", "Only list files whose names match this glob, e.g. *_test.go")
+	listCmd.Flags().StringP("language", "l", "", "Parse files as this language instead of guessing from the extension (--type definitions)")
+	addJSONFlag(listCmd)
+	rootCmd.AddCommand(listCmd)
+
+	// Run command
+	runCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run a shell command in the sandbox",
+		Long: `Run a shell command in the workspace under the sandbox policy. Output is
+shown as the command runs; with --capture it is collected, capped at
+sandbox.max_output_bytes per stream, and printed when the command ends.
+--json implies --capture.
+
+Commands run this way need no approval, but sandbox.deny and read-only mode
+still apply. The exit status is the command's own, 124 if it timed out, 126
+if the sandbox refused it and 130 if it was interrupted.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			command, _ := cmd.Flags().GetString("command")
+			if command == "" {
+				usageError(cmd, "--command is required")
+			}
+			workdir, _ := cmd.Flags().GetString("workdir")
+			timeout, _ := cmd.Flags().GetInt("timeout")
+			capture, _ := cmd.Flags().GetBool("capture")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			tool, err := toolManager.GetTool("command")
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(exitFailure)
+			}
+			commandTool := tool.(*tools.Command)
+			// The user typed the command, so it needs no further approval;
+			// deny patterns and read-only mode still apply
+			commandTool.SetApprover(approveAll{})
+			if !capture && !jsonOutput {
+				commandTool.Stdout, commandTool.Stderr = os.Stdout, os.Stderr
+			}
+
+			// Ctrl-C kills the command rather than leaving it running
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			result, err := toolManager.ExecuteContext(ctx, "command", map[string]interface{}{
+				"command": command,
+				"workdir": workdir,
+				"timeout": timeout,
+			})
+			stop()
+
+			if !capture && !jsonOutput {
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+				}
+				os.Exit(exitCode(result, err))
+			}
+			finish(cmd, result, err, func() {
+				printCommandOutput(result)
+			})
+		},
+	}
+	runCmd.Flags().StringP("command", "c", "", "Shell command line to run")
+	runCmd.Flags().String("workdir", "", "Directory to run in, inside the workspace (defaults to workspace.root)")
+	runCmd.Flags().Int("timeout", 0, "Seconds before the command is killed (defaults to sandbox.timeout)")
+	runCmd.Flags().Bool("capture", false, "Collect the output and print it when the command ends")
+	addJSONFlag(runCmd)
+	rootCmd.AddCommand(runCmd)
+}
+
+// approveAll approves every command it is asked about
+type approveAll struct{}
+
+func (approveAll) ApproveCommand(command, dir string) (sandbox.Approval, error) {
+	return sandbox.ApproveOnce, nil
+}
+
+func addJSONFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("json", false, "Print the tool result as JSON")
+}
+
+// usageError reports invalid flags and exits
+func usageError(cmd *cobra.Command, message string) {
+	fmt.Fprintf(os.Stderr, "Error: %s\nRun '%s --help' for usage.\n", message, cmd.CommandPath())
+	os.Exit(exitUsage)
+}
+
+// finish prints a tool result, as JSON with --json or else with show on
+// success, and exits with the matching code on failure
+func finish(cmd *cobra.Command, result *types.ToolResult, err error, show func()) {
+	if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+		data, jsonErr := tools.RenderJSON(result)
+		if jsonErr != nil {
+			fmt.Fprintln(os.Stderr, "Error:", jsonErr)
+			os.Exit(exitFailure)
+		}
+		fmt.Println(string(data))
+	} else if err != nil {
+		if result != nil && result.Tool == "command" {
+			printCommandOutput(result)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	} else {
+		show()
+	}
+
+	if err != nil {
+		os.Exit(exitCode(result, err))
+	}
+}
+
+// printCommandOutput prints a captured command's output to the matching
+// streams
+func printCommandOutput(result *types.ToolResult) {
+	data, _ := result.Data.(map[string]interface{})
+	stdout, _ := data["stdout"].(string)
+	stderr, _ := data["stderr"].(string)
+	fmt.Fprint(os.Stdout, stdout)
+	fmt.Fprint(os.Stderr, stderr)
+}
+
+// exitCode maps the outcome of a tool call to an exit status
+func exitCode(result *types.ToolResult, err error) int {
+	var validation *tools.ValidationError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.Is(err, sandbox.ErrDenied), errors.Is(err, workspace.ErrOutsideWorkspace), errors.Is(err, workspace.ErrProtected):
+		return exitDenied
+	case errors.As(err, &validation):
+		return exitUsage
+	}
+
+	if data, ok := result.Data.(map[string]interface{}); ok {
+		if timedOut, _ := data["timed_out"].(bool); timedOut {
+			return exitTimeout
+		}
+		if code, _ := data["exit_code"].(int); code > 0 {
+			return code
+		}
+	}
+	return exitFailure
+}
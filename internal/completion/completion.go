@@ -0,0 +1,271 @@
+// Package completion fills in code at a cursor with the code model. The
+// prompt is built from the code around the cursor, as a fill-in-the-middle
+// prefix and suffix, and from related code retrieved from the index.
+package completion
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/azhany/codecli/internal/llm"
+	"github.com/azhany/codecli/internal/parser"
+	"github.com/azhany/codecli/internal/search"
+	"github.com/azhany/codecli/internal/types"
+)
+
+// Defaults for a Request
+const (
+	DefaultContextLines = 100
+	DefaultMaxTokens    = 128
+	DefaultRelated      = 3
+)
+
+// maxRelatedLines caps the lines included from each related chunk
+const maxRelatedLines = 40
+
+// Request describes where to complete
+type Request struct {
+	Path    string // File being edited, for its language and related code
+	Content string // Its content
+
+	Line   int // Cursor line, counting from 1; 0 is the end of the file
+	Column int // Cursor column in characters, counting from 1; 0 is the end of the line
+
+	ContextLines int      // Lines before and after the cursor to include; 0 includes the whole file
+	MaxTokens    int      // Tokens to generate at most
+	Related      int      // Related chunks to include in the prompt
+	Stop         []string // Stop sequences in addition to the language's
+	Model        string   // Defaults to ollama.code_model
+}
+
+// Result is a completion and how it was produced
+type Result struct {
+	Completion string `json:"completion"`
+	Line       int    `json:"line"`                // Cursor position the completion belongs at
+	Column     int    `json:"column"`              // Counting from 1
+	Offset     int    `json:"offset"`              // Byte offset of the cursor in the content
+	Truncated  bool   `json:"truncated,omitempty"` // Cut off by the token limit
+
+	Related []types.SearchResult `json:"related,omitempty"` // Chunks added to the prompt
+	// RetrievalError explains why related code could not be retrieved; the
+	// completion is made without it
+	RetrievalError string `json:"retrieval_error,omitempty"`
+}
+
+// Completer completes code with the code model
+type Completer struct {
+	client    *llm.Client
+	retriever search.Engine // May be nil
+}
+
+// New creates a completer. retriever finds code related to the cursor; it
+// may be nil.
+func New(client *llm.Client, retriever search.Engine) *Completer {
+	return &Completer{client: client, retriever: retriever}
+}
+
+// Complete asks the code model for the code at the cursor
+func (c *Completer) Complete(ctx context.Context, req Request) (*Result, error) {
+	offset, line, column, err := Cursor(req.Content, req.Line, req.Column)
+	if err != nil {
+		return nil, err
+	}
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = DefaultMaxTokens
+	}
+	result := &Result{Line: line, Column: column, Offset: offset}
+
+	prefix, suffix := window(req.Content, offset, req.ContextLines)
+	language := parser.LanguageOf(req.Path)
+
+	if c.retriever != nil && req.Related > 0 {
+		related, err := c.related(req.Path, line, prefix, suffix, req.Related)
+		if err != nil {
+			result.RetrievalError = err.Error()
+		}
+		result.Related = related
+	}
+
+	resp, err := c.client.Generate(ctx, llm.GenerateRequest{
+		Model:  req.Model,
+		Prompt: relatedHeader(result.Related, language) + prefix,
+		Suffix: suffix,
+		Options: &llm.GenerateOptions{
+			NumPredict:  req.MaxTokens,
+			Temperature: 0.2,
+			Stop:        append(StopSequences(language), req.Stop...),
+		},
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "does not support insert") {
+			return nil, fmt.Errorf("completion failed: %w (use a code model with fill-in-the-middle support, e.g. codellama:7b-code)", err)
+		}
+		return nil, fmt.Errorf("completion failed: %w", err)
+	}
+	result.Completion = resp.Response
+	result.Truncated = resp.DoneReason == "length"
+	return result, nil
+}
+
+// related retrieves chunks like the code before the cursor, leaving out
+// the code already in the prompt
+func (c *Completer) related(path string, line int, prefix, suffix string, limit int) ([]types.SearchResult, error) {
+	query := lastLines(prefix, 10) + firstLines(suffix, 3)
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+
+	// Ask for extra results, as chunks of the window itself are dropped
+	results, err := c.retriever.Search(query, limit+5)
+	if err != nil {
+		return nil, err
+	}
+
+	first := line - strings.Count(prefix, "\n")
+	last := line + strings.Count(suffix, "\n")
+	var related []types.SearchResult
+	for _, r := range results {
+		if samePath(r.Path, path) && r.Line <= last && r.Line+strings.Count(r.Content, "\n") >= first {
+			continue
+		}
+		related = append(related, r)
+		if len(related) == limit {
+			break
+		}
+	}
+	return related, nil
+}
+
+// Cursor returns the byte offset of a cursor given as a 1-based line and
+// column, along with the resolved line and column. Line 0 is the end of the
+// content and column 0 the end of the line.
+func Cursor(content string, line, column int) (offset, resolvedLine, resolvedColumn int, err error) {
+	lines := strings.SplitAfter(content, "\n")
+	if line == 0 {
+		line = len(lines)
+		column = 0
+	}
+	if line < 1 || line > len(lines) {
+		return 0, 0, 0, fmt.Errorf("line %d is outside the file, which has %d lines", line, len(lines))
+	}
+
+	for _, l := range lines[:line-1] {
+		offset += len(l)
+	}
+	text := strings.TrimSuffix(lines[line-1], "\n")
+	length := utf8.RuneCountInString(text)
+	switch {
+	case column == 0:
+		column = length + 1
+	case column < 1 || column > length+1:
+		return 0, 0, 0, fmt.Errorf("column %d is outside line %d, which has %d characters", column, line, length)
+	}
+
+	// Advance column-1 characters into the line
+	n := 0
+	for i := range text {
+		if n == column-1 {
+			return offset + i, line, column, nil
+		}
+		n++
+	}
+	return offset + len(text), line, column, nil
+}
+
+// StopSequences returns sequences that end a completion in a language,
+// mostly the starts of new top-level declarations
+func StopSequences(language string) []string {
+	stops := []string{"\n\n\n"}
+	switch language {
+	case "go":
+		stops = append(stops, "\nfunc ", "\ntype ", "\nvar ", "\nconst ", "\n//")
+	case "python":
+		stops = append(stops, "\ndef ", "\nclass ", "\nasync def ", "\nif __name__", "\n@", "\n#")
+	case "javascript", "typescript":
+		stops = append(stops, "\nfunction ", "\nclass ", "\nexport ", "\nimport ", "\n//")
+	case "java":
+		stops = append(stops, "\npublic ", "\nclass ", "\nimport ", "\n//")
+	case "c", "cpp":
+		stops = append(stops, "\n#include", "\n#define", "\n//", "\n/*")
+	case "php":
+		stops = append(stops, "\nfunction ", "\nclass ", "\n?>", "\n//")
+	}
+	return stops
+}
+
+// window returns the text before and after offset, limited to lines lines
+// each way unless lines is 0
+func window(content string, offset, lines int) (prefix, suffix string) {
+	prefix, suffix = content[:offset], content[offset:]
+	if lines <= 0 {
+		return prefix, suffix
+	}
+	// The cursor line counts as context on both sides
+	return lastLines(prefix, lines+1), firstLines(suffix, lines+1)
+}
+
+// lastLines returns the last n lines of s, the last of which may be partial
+func lastLines(s string, n int) string {
+	end := len(s)
+	for i := 0; i < n; i++ {
+		idx := strings.LastIndexByte(s[:end], '\n')
+		if idx < 0 {
+			return s
+		}
+		end = idx
+	}
+	return s[end+1:]
+}
+
+// firstLines returns the first n lines of s, the first of which may be
+// partial
+func firstLines(s string, n int) string {
+	start := 0
+	for i := 0; i < n; i++ {
+		idx := strings.IndexByte(s[start:], '\n')
+		if idx < 0 {
+			return s
+		}
+		start += idx + 1
+	}
+	return s[:start]
+}
+
+// relatedHeader formats related chunks as comments to put ahead of the
+// prefix, each labelled with where it comes from
+func relatedHeader(related []types.SearchResult, language string) string {
+	if len(related) == 0 {
+		return ""
+	}
+	comment := commentPrefix(language)
+
+	var b strings.Builder
+	for _, r := range related {
+		fmt.Fprintf(&b, "%s Related code from %s:%d\n", comment, filepath.ToSlash(r.Path), r.Line)
+		lines := strings.Split(strings.TrimRight(r.Content, "\n"), "\n")
+		if len(lines) > maxRelatedLines {
+			lines = append(lines[:maxRelatedLines], "...")
+		}
+		for _, line := range lines {
+			fmt.Fprintf(&b, "%s %s\n", comment, line)
+		}
+		fmt.Fprintf(&b, "%s\n", comment)
+	}
+	return b.String()
+}
+
+func commentPrefix(language string) string {
+	if language == "python" {
+		return "#"
+	}
+	return "//"
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
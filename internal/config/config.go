@@ -2,6 +2,10 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -16,15 +20,39 @@ var Config = struct {
 		Timeout        string `mapstructure:"timeout"`
 	}
 	NGT struct {
-		IndexPath string `mapstructure:"index_path"`
-		Dimension int    `mapstructure:"dimension"`
-		EdgeSize  int    `mapstructure:"edge_size"`
-		BatchSize int    `mapstructure:"batch_size"`
+		IndexPath      string `mapstructure:"index_path"`
+		IndexType      string `mapstructure:"index_type"`
+		Dimension      int    `mapstructure:"dimension"`
+		EdgeSize       int    `mapstructure:"edge_size"`
+		EfConstruction int    `mapstructure:"ef_construction"`
+		EfSearch       int    `mapstructure:"ef_search"`
+		Quantization   string `mapstructure:"quantization"`
+		BatchSize      int    `mapstructure:"batch_size"`
 	}
 	Workspace struct {
 		Root              string   `mapstructure:"root"`
 		ExcludePatterns   []string `mapstructure:"exclude_patterns"`
 		IncludeExtensions []string `mapstructure:"include_extensions"`
+		MaxFileSize       int64    `mapstructure:"max_file_size"`
+		AllowedRoots      []string `mapstructure:"allowed_roots"`
+		ProtectedPaths    []string `mapstructure:"protected_paths"`
+	}
+	Search struct {
+		SemanticWeight float64 `mapstructure:"semantic_weight"`
+		LexicalWeight  float64 `mapstructure:"lexical_weight"`
+		RRFK           int     `mapstructure:"rrf_k"`
+	}
+	Sandbox struct {
+		Allow          []string `mapstructure:"allow"`
+		Deny           []string `mapstructure:"deny"`
+		ReadOnly       bool     `mapstructure:"read_only"`
+		Isolate        bool     `mapstructure:"isolate"`
+		MaxCPUSeconds  int      `mapstructure:"max_cpu_seconds"`
+		MaxMemoryMB    int      `mapstructure:"max_memory_mb"`
+		MaxFileSizeMB  int      `mapstructure:"max_file_size_mb"`
+		Timeout        string   `mapstructure:"timeout"`
+		MaxOutputBytes int      `mapstructure:"max_output_bytes"`
+		ConfirmWrites  bool     `mapstructure:"confirm_writes"`
 	}
 	Logging struct {
 		Level  string `mapstructure:"level"`
@@ -46,24 +74,72 @@ var Config = struct {
 		Timeout:        "30s",
 	},
 	NGT: struct {
-		IndexPath string `mapstructure:"index_path"`
-		Dimension int    `mapstructure:"dimension"`
-		EdgeSize  int    `mapstructure:"edge_size"`
-		BatchSize int    `mapstructure:"batch_size"`
+		IndexPath      string `mapstructure:"index_path"`
+		IndexType      string `mapstructure:"index_type"`
+		Dimension      int    `mapstructure:"dimension"`
+		EdgeSize       int    `mapstructure:"edge_size"`
+		EfConstruction int    `mapstructure:"ef_construction"`
+		EfSearch       int    `mapstructure:"ef_search"`
+		Quantization   string `mapstructure:"quantization"`
+		BatchSize      int    `mapstructure:"batch_size"`
 	}{
-		IndexPath: ".codecli/index",
-		Dimension: 768,
-		EdgeSize:  10,
-		BatchSize: 100,
+		IndexPath:      ".codecli/index",
+		IndexType:      "hnsw",
+		Dimension:      768,
+		EdgeSize:       10,
+		EfConstruction: 200,
+		EfSearch:       64,
+		Quantization:   "none",
+		BatchSize:      100,
 	},
 	Workspace: struct {
 		Root              string   `mapstructure:"root"`
 		ExcludePatterns   []string `mapstructure:"exclude_patterns"`
 		IncludeExtensions []string `mapstructure:"include_extensions"`
+		MaxFileSize       int64    `mapstructure:"max_file_size"`
+		AllowedRoots      []string `mapstructure:"allowed_roots"`
+		ProtectedPaths    []string `mapstructure:"protected_paths"`
 	}{
 		Root:              ".",
-		ExcludePatterns:   []string{"*.git*", "node_modules", "*.log", "*.tmp"},
+		ExcludePatterns:   []string{"*.git*", "/.codecli", "node_modules", "*.log", "*.tmp"},
 		IncludeExtensions: []string{".go", ".py", ".js", ".ts", ".java", ".cpp", ".c", ".h", ".php"},
+		MaxFileSize:       1 << 20,
+		ProtectedPaths:    []string{".git", "/.codecli", "/config.yaml", "/configs/config.yaml"},
+	},
+	Search: struct {
+		SemanticWeight float64 `mapstructure:"semantic_weight"`
+		LexicalWeight  float64 `mapstructure:"lexical_weight"`
+		RRFK           int     `mapstructure:"rrf_k"`
+	}{
+		SemanticWeight: 1.0,
+		LexicalWeight:  1.0,
+		RRFK:           60,
+	},
+	Sandbox: struct {
+		Allow          []string `mapstructure:"allow"`
+		Deny           []string `mapstructure:"deny"`
+		ReadOnly       bool     `mapstructure:"read_only"`
+		Isolate        bool     `mapstructure:"isolate"`
+		MaxCPUSeconds  int      `mapstructure:"max_cpu_seconds"`
+		MaxMemoryMB    int      `mapstructure:"max_memory_mb"`
+		MaxFileSizeMB  int      `mapstructure:"max_file_size_mb"`
+		Timeout        string   `mapstructure:"timeout"`
+		MaxOutputBytes int      `mapstructure:"max_output_bytes"`
+		ConfirmWrites  bool     `mapstructure:"confirm_writes"`
+	}{
+		Allow: []string{
+			"ls", "ls *", "pwd", "cat *", "head *", "tail *", "wc *",
+			"git status*", "git diff*", "git log*", "git show*",
+		},
+		Deny: []string{
+			"sudo *", "su *", "rm -rf /*", "rm -rf ~*", "mkfs*", "dd *",
+			"shutdown*", "reboot*", "curl * | *sh*", "wget * | *sh*",
+		},
+		MaxCPUSeconds:  300,
+		MaxFileSizeMB:  100,
+		Timeout:        "2m",
+		MaxOutputBytes: 8192,
+		ConfirmWrites:  true,
 	},
 	Logging: struct {
 		Level  string `mapstructure:"level"`
@@ -76,18 +152,129 @@ var Config = struct {
 	},
 }
 
-// LoadConfig loads the configuration from file
-func LoadConfig() error {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("$HOME/.config/codecli")
+// ProjectFile is the project configuration file, relative to the working
+// directory
+const ProjectFile = ".codecli/config.yaml"
 
-	if err := viper.ReadInConfig(); err == nil {
-		if err := viper.Unmarshal(&Config); err != nil {
-			return fmt.Errorf("error unmarshaling config: %v", err)
+// EnvPrefix starts the names of the environment variables overriding
+// settings, such as CODECLI_OLLAMA_URL for ollama.url
+const EnvPrefix = "CODECLI"
+
+// filesUsed lists the configuration files read, lowest precedence first
+var filesUsed []string
+
+// LoadConfig loads the configuration. Each of these overrides the ones
+// before it: the defaults, the user configuration file (see UserFile), the
+// project files config.yaml and .codecli/config.yaml in the working
+// directory, and CODECLI_* environment variables. A file given as file is
+// read instead of the user and project files, and must exist.
+func LoadConfig(file string) error {
+	// Registering every setting lets the environment override any of them
+	for _, key := range Keys() {
+		value, _ := Value(key)
+		viper.SetDefault(key, value)
+	}
+
+	files := []string{UserFile(), "config.yaml", ProjectFile}
+	if file != "" {
+		files = []string{file}
+	}
+	for _, path := range files {
+		if err := mergeFile(path, file != ""); err != nil {
+			return err
 		}
 	}
 
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	for _, key := range Keys() {
+		if name := EnvName(key); os.Getenv(name) != "" {
+			sources[key] = "$" + name
+		}
+	}
+
+	// Decoding into the defaults would merge a shorter list into the default
+	// one, so start from zero values; viper holds the defaults now
+	config := reflect.New(reflect.TypeOf(Config))
+	if err := viper.Unmarshal(config.Interface()); err != nil {
+		return fmt.Errorf("error unmarshaling config: %v", err)
+	}
+	reflect.ValueOf(&Config).Elem().Set(config.Elem())
 	return nil
 }
+
+// mergeFile merges the settings of a configuration file into the ones read
+// so far. A missing file is skipped unless required.
+func mergeFile(path string, required bool) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) && !required {
+		return nil
+	}
+
+	file := viper.New()
+	file.SetConfigFile(path)
+	file.SetConfigType("yaml")
+	if err := file.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading config: %v", err)
+	}
+	if err := viper.MergeConfigMap(file.AllSettings()); err != nil {
+		return fmt.Errorf("error reading config %s: %v", path, err)
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	for _, key := range Keys() {
+		if file.InConfig(key) {
+			sources[key] = path
+		}
+	}
+	filesUsed = append(filesUsed, path)
+	return nil
+}
+
+// Override sets a setting for this run only, as a command-line flag does.
+// source describes where the value came from, e.g. "--model".
+func Override(key, text, source string) error {
+	value, err := ParseValue(key, text)
+	if err != nil {
+		return err
+	}
+	v, _ := field(key)
+	v.Set(reflect.ValueOf(value).Convert(v.Type()))
+	viper.Set(key, value)
+	sources[strings.ToLower(key)] = source
+	return nil
+}
+
+// UserFile returns the user configuration file,
+// $HOME/.config/codecli/config.yaml, or "" if there is no home directory
+func UserFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "codecli", "config.yaml")
+}
+
+// EnvName returns the environment variable overriding a setting
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// FileUsed returns the configuration file read with the highest precedence,
+// if any
+func FileUsed() string {
+	if len(filesUsed) == 0 {
+		return ""
+	}
+	return filesUsed[len(filesUsed)-1]
+}
+
+// FilesUsed returns the configuration files read, lowest precedence first
+func FilesUsed() []string {
+	return filesUsed
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetInFile sets a setting in a YAML configuration file, creating the file
+// if needed. Only the text of the value changes, so the rest of the file,
+// comments and layout included, is kept. A replaced value keeps its quoting
+// style, and a list written one item per line stays that way.
+func SetInFile(path, key string, value interface{}) error {
+	if _, err := field(key); err != nil {
+		return err
+	}
+	sectionName, name, _ := strings.Cut(strings.ToLower(key), ".")
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	lines := strings.Split(string(data), "\n")
+
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		if root = doc.Content[0]; root.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s does not hold a mapping of settings", path)
+		}
+	}
+
+	sectionKey, section := lookup(root, sectionName)
+	switch {
+	case section == nil:
+		// Add the section at the end
+		text := strings.TrimRight(string(data), "\n")
+		if text != "" {
+			text += "\n\n"
+		}
+		text += fmt.Sprintf("%s:\n  %s: %s\n", sectionName, name, renderInline(value, yaml.DoubleQuotedStyle))
+		return writeFile(path, []byte(text))
+
+	case section.Kind == yaml.ScalarNode && section.Tag == "!!null":
+		// "section:" with nothing under it
+		line := sectionKey.Line - 1
+		lines = insertLines(lines, line+1, fmt.Sprintf("  %s: %s", name, renderInline(value, yaml.DoubleQuotedStyle)))
+
+	case section.Kind != yaml.MappingNode:
+		return fmt.Errorf("%s in %s is not a mapping of settings", sectionName, path)
+
+	default:
+		keyNode, valueNode := lookup(section, name)
+		if valueNode == nil {
+			// Add the setting after the last line of the section
+			indent := strings.Repeat(" ", section.Content[0].Column-1)
+			lines = insertLines(lines, lastLine(section), fmt.Sprintf("%s%s: %s", indent, name, renderInline(value, yaml.DoubleQuotedStyle)))
+		} else if lines, err = replaceValue(lines, keyNode, valueNode, value); err != nil {
+			return fmt.Errorf("cannot set %s in %s: %v", key, path, err)
+		}
+	}
+
+	return writeFile(path, []byte(strings.Join(lines, "\n")))
+}
+
+// lookup returns the key and value nodes of name in a mapping node
+func lookup(mapping *yaml.Node, name string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.ToLower(mapping.Content[i].Value) == name {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// replaceValue replaces the text of valueNode, the value of keyNode, in
+// lines
+func replaceValue(lines []string, keyNode, valueNode *yaml.Node, value interface{}) ([]string, error) {
+	switch {
+	case valueNode.Kind == yaml.SequenceNode && valueNode.Style&yaml.FlowStyle == 0:
+		// A list with one item per line
+		items, _ := value.([]string)
+		first, last := valueNode.Line-1, lastLine(valueNode)
+		var replacement []string
+		if len(items) == 0 {
+			// Make it "key: []"
+			line := lines[keyNode.Line-1]
+			at := valueEnd(line, keyNode)
+			lines[keyNode.Line-1] = line[:at] + " []" + line[at:]
+		} else {
+			indent := strings.Repeat(" ", valueNode.Column-1)
+			for _, item := range items {
+				replacement = append(replacement, indent+"- "+renderInline(item, itemStyle(valueNode)))
+			}
+		}
+		return append(lines[:first], append(replacement, lines[last:]...)...), nil
+
+	case valueNode.Kind == yaml.ScalarNode && valueNode.Tag == "!!null" && valueNode.Value == "":
+		// "key:" with nothing after it
+		line := lines[keyNode.Line-1]
+		at := valueEnd(line, keyNode)
+		lines[keyNode.Line-1] = line[:at] + " " + renderInline(value, yaml.DoubleQuotedStyle) + line[at:]
+		return lines, nil
+
+	case valueNode.Kind == yaml.ScalarNode || valueNode.Kind == yaml.SequenceNode:
+		if valueNode.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			return nil, fmt.Errorf("multi-line values must be edited by hand")
+		}
+		line := lines[valueNode.Line-1]
+		start := columnOffset(line, valueNode.Column)
+		end := start + tokenLength(line[start:], valueNode)
+		style := valueNode.Style
+		if valueNode.Kind == yaml.SequenceNode {
+			style = itemStyle(valueNode)
+		}
+		text := renderInline(value, style)
+		lines[valueNode.Line-1] = line[:start] + text + keepColumn(line[end:], end-start-len(text))
+		return lines, nil
+	}
+	return nil, fmt.Errorf("unexpected value at line %d", valueNode.Line)
+}
+
+// renderInline renders a value on one line: lists in flow style, strings
+// in the given quoting style where YAML allows it
+func renderInline(value interface{}, style yaml.Style) string {
+	var node yaml.Node
+	switch v := value.(type) {
+	case []string:
+		node = yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range v {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item, Style: style})
+		}
+	case string:
+		node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v, Style: style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)}
+	default:
+		node = yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v)}
+		if f, ok := v.(float64); ok {
+			node.Value = strconv.FormatFloat(f, 'f', -1, 64)
+			if !strings.Contains(node.Value, ".") {
+				node.Value += ".0"
+			}
+		}
+	}
+	out, err := yaml.Marshal(&node)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// itemStyle returns the quoting style of a list's items
+func itemStyle(seq *yaml.Node) yaml.Style {
+	if len(seq.Content) > 0 {
+		return seq.Content[0].Style
+	}
+	return yaml.DoubleQuotedStyle
+}
+
+// tokenLength returns the length of the scalar or flow list at the start
+// of text
+func tokenLength(text string, node *yaml.Node) int {
+	switch {
+	case node.Kind == yaml.SequenceNode:
+		depth := 0
+		var quote byte
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+			switch {
+			case quote != 0:
+				if c == '\\' && quote == '"' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '[':
+				depth++
+			case c == ']':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+		}
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\\' {
+				i++
+			} else if text[i] == '"' {
+				return i + 1
+			}
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	default:
+		// A plain scalar ends at a comment or the end of the line
+		end := len(text)
+		if idx := strings.Index(text, " #"); idx >= 0 {
+			end = idx
+		}
+		return len(strings.TrimRight(text[:end], " \t"))
+	}
+	return len(text)
+}
+
+// keepColumn keeps a trailing comment in its column when the value before
+// it grows or shrinks by -delta characters
+func keepColumn(rest string, delta int) string {
+	comment := strings.TrimLeft(rest, " ")
+	if !strings.HasPrefix(comment, "#") {
+		return rest
+	}
+	spaces := len(rest) - len(comment) + delta
+	if spaces < 1 {
+		spaces = 1
+	}
+	return strings.Repeat(" ", spaces) + comment
+}
+
+// valueEnd returns the offset in line just after the colon following a key
+func valueEnd(line string, keyNode *yaml.Node) int {
+	start := columnOffset(line, keyNode.Column)
+	if idx := strings.IndexByte(line[start:], ':'); idx >= 0 {
+		return start + idx + 1
+	}
+	return len(line)
+}
+
+// columnOffset converts a 1-based character column to a byte offset
+func columnOffset(line string, column int) int {
+	n := 1
+	for i := range line {
+		if n == column {
+			return i
+		}
+		n++
+	}
+	return len(line)
+}
+
+// lastLine returns the 1-based last line holding any part of node
+func lastLine(node *yaml.Node) int {
+	last := node.Line
+	for _, child := range node.Content {
+		if l := lastLine(child); l > last {
+			last = l
+		}
+	}
+	return last
+}
+
+// insertLines inserts text as lines[at], moving the rest down
+func insertLines(lines []string, at int, text string) []string {
+	lines = append(lines, "")
+	copy(lines[at+1:], lines[at:])
+	lines[at] = text
+	return lines
+}
+
+// writeFile replaces path atomically, keeping the mode of an existing file
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
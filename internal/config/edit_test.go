@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetInFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string // "" for a file that does not exist yet
+		key     string
+		value   interface{}
+		want    string
+	}{
+		{
+			name:    "scalar keeps its comment",
+			initial: "ollama:\n  chat_model: llama2   # the default\n  timeout: 30s\n",
+			key:     "ollama.chat_model",
+			value:   "phi",
+			want:    "ollama:\n  chat_model: phi      # the default\n  timeout: 30s\n",
+		},
+		{
+			name:    "longer scalar pushes its comment along",
+			initial: "ollama:\n  chat_model: llama2   # the default\n",
+			key:     "ollama.chat_model",
+			value:   "qwen2.5-coder",
+			want:    "ollama:\n  chat_model: qwen2.5-coder # the default\n",
+		},
+		{
+			name:    "quoted scalar keeps its quotes",
+			initial: "ollama:\n  chat_model: 'llama2'\n",
+			key:     "ollama.chat_model",
+			value:   "mistral",
+			want:    "ollama:\n  chat_model: 'mistral'\n",
+		},
+		{
+			name:    "block list",
+			initial: "sandbox:\n  allow:\n    - ls*\n    - cat *\n  read_only: false\n",
+			key:     "sandbox.allow",
+			value:   []string{"git status", "git diff*"},
+			want:    "sandbox:\n  allow:\n    - git status\n    - git diff*\n  read_only: false\n",
+		},
+		{
+			name:    "flow list",
+			initial: "sandbox:\n  allow: [ls, pwd]\n",
+			key:     "sandbox.allow",
+			value:   []string{"ls", "git status"},
+			want:    "sandbox:\n  allow: [ls, git status]\n",
+		},
+		{
+			name:    "block list emptied",
+			initial: "sandbox:\n  allow:\n    - ls\n  read_only: false\n",
+			key:     "sandbox.allow",
+			value:   []string{},
+			want:    "sandbox:\n  allow: []\n  read_only: false\n",
+		},
+		{
+			name:    "missing key",
+			initial: "sandbox:\n  allow: [ls]\n\nollama:\n  chat_model: llama2\n",
+			key:     "sandbox.read_only",
+			value:   true,
+			want:    "sandbox:\n  allow: [ls]\n  read_only: true\n\nollama:\n  chat_model: llama2\n",
+		},
+		{
+			name:    "missing section",
+			initial: "ollama:\n  chat_model: llama2\n",
+			key:     "sandbox.read_only",
+			value:   true,
+			want:    "ollama:\n  chat_model: llama2\n\nsandbox:\n  read_only: true\n",
+		},
+		{
+			name:    "null section",
+			initial: "sandbox:\nollama:\n  chat_model: llama2\n",
+			key:     "sandbox.timeout",
+			value:   "1m",
+			want:    "sandbox:\n  timeout: \"1m\"\nollama:\n  chat_model: llama2\n",
+		},
+		{
+			name:    "new file",
+			initial: "",
+			key:     "ollama.chat_model",
+			value:   "llama3",
+			want:    "ollama:\n  chat_model: \"llama3\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.initial != "" {
+				if err := os.WriteFile(path, []byte(tt.initial), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := SetInFile(path, tt.key, tt.value); err != nil {
+				t.Fatalf("SetInFile: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetInFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		key     string
+	}{
+		{"unknown setting", "ollama:\n  chat_model: llama2\n", "ollama.nonsense"},
+		{"section is not a mapping", "ollama: llama2\n", "ollama.chat_model"},
+		{"multi-line value", "ollama:\n  chat_model: |\n    llama2\n", "ollama.chat_model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.initial), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetInFile(path, tt.key, "x"); err == nil {
+				t.Error("SetInFile succeeded")
+			}
+			if got, _ := os.ReadFile(path); string(got) != tt.initial {
+				t.Errorf("file changed to:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SourceDefault is the source of settings left at their built-in default
+const SourceDefault = "default"
+
+// sources records where settings not at their default came from, by key
+var sources = make(map[string]string)
+
+// Keys returns the keys of all settings, such as "ollama.url", in the order
+// they are declared
+func Keys() []string {
+	var keys []string
+	sections := reflect.ValueOf(&Config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Type().Field(i)
+		for j := 0; j < section.Type.NumField(); j++ {
+			keys = append(keys, strings.ToLower(section.Name)+"."+section.Type.Field(j).Tag.Get("mapstructure"))
+		}
+	}
+	return keys
+}
+
+// field returns the field of Config holding a setting
+func field(key string) (reflect.Value, error) {
+	sectionName, name, ok := strings.Cut(strings.ToLower(key), ".")
+	if ok {
+		sections := reflect.ValueOf(&Config).Elem()
+		for i := 0; i < sections.NumField(); i++ {
+			if strings.ToLower(sections.Type().Field(i).Name) != sectionName {
+				continue
+			}
+			section := sections.Field(i)
+			for j := 0; j < section.NumField(); j++ {
+				if section.Type().Field(j).Tag.Get("mapstructure") == name {
+					return section.Field(j), nil
+				}
+			}
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("unknown setting %q; run `codecli config show` for the list", key)
+}
+
+// Value returns the effective value of a setting
+func Value(key string) (interface{}, error) {
+	v, err := field(key)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// Source describes where the value of a setting came from: a configuration
+// file, an environment variable such as $CODECLI_OLLAMA_URL, a flag, or
+// SourceDefault
+func Source(key string) string {
+	if source, ok := sources[strings.ToLower(key)]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// ParseValue converts text given on the command line to the type of a
+// setting. Lists are separated by commas.
+func ParseValue(key, text string) (interface{}, error) {
+	v, err := field(key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return text, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", key, text)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an integer, got %q", key, text)
+		}
+		return n, nil
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number, got %q", key, text)
+		}
+		return f, nil
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%s cannot be set from the command line", key)
+}
+
+// FormatValue renders a setting's value for display
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return `""`
+		}
+		return v
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+)
+
+// starter is a commented configuration holding the defaults
+//
+//go:embed starter.yaml
+var starter []byte
+
+// WriteStarter writes a commented configuration file holding the defaults.
+// An existing file is only replaced with force.
+func WriteStarter(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists", path)
+	}
+	return writeFile(path, starter)
+}
@@ -0,0 +1,334 @@
+// Package diff computes line diffs of text in unified format and applies
+// unified diffs
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change
+const DefaultContext = 3
+
+const noNewline = `\ No newline at end of file`
+
+// Op is the kind of an edit
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Edit is one line of a line diff. Text includes the line's trailing
+// newline, if it has one.
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Lines splits text into lines, keeping their newlines
+func Lines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Stats counts the inserted and deleted lines of a diff
+func Stats(edits []Edit) (added, removed int) {
+	for _, e := range edits {
+		switch e.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// maxCost bounds the work, in positions visited, spent looking for a
+// shortest edit path through one part of a diff. Past it, the part is
+// shown as replaced outright, so that very different large files still
+// diff quickly.
+const maxCost = 1 << 26
+
+// Compute returns the edits turning a into b, using Myers' algorithm on
+// lines
+func Compute(a, b string) []Edit {
+	x, y := Lines(a), Lines(b)
+
+	// Compare numbers standing for the lines rather than the lines
+	ids := make(map[string]int)
+	number := func(lines []string) []int {
+		numbers := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			numbers[i] = id
+		}
+		return numbers
+	}
+	c := comparison{a: x, b: y, edits: make([]Edit, 0, len(x)+len(y))}
+	c.compare(number(x), number(y), 0, 0)
+	return c.edits
+}
+
+// comparison collects the edits turning a into b
+type comparison struct {
+	a, b  []string
+	edits []Edit
+}
+
+// compare appends the edits turning a[i:i+len(x)] into b[j:j+len(y)], where
+// x and y number those lines. The common prefix and suffix are split off
+// first; what remains is divided at a point on a shortest edit path, and
+// each side compared in turn, which keeps memory linear in the input.
+func (c *comparison) compare(x, y []int, i, j int) {
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	for k := 0; k < prefix; k++ {
+		c.edits = append(c.edits, Edit{Equal, c.a[i+k]})
+	}
+	x, y = x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+	i, j = i+prefix, j+prefix
+	if sx, sy := split(x, y); sx < 0 {
+		// Nothing in common, or too costly to find out: delete all of one
+		// and insert all of the other
+		for _, line := range c.a[i : i+len(x)] {
+			c.edits = append(c.edits, Edit{Delete, line})
+		}
+		for _, line := range c.b[j : j+len(y)] {
+			c.edits = append(c.edits, Edit{Insert, line})
+		}
+	} else {
+		c.compare(x[:sx], y[:sy], i, j)
+		c.compare(x[sx:], y[sy:], i+sx, j+sy)
+	}
+	for k := len(x); k < len(x)+suffix; k++ {
+		c.edits = append(c.edits, Edit{Equal, c.a[i+k]})
+	}
+}
+
+// split finds the point (i, j) where a shortest edit path from a to b
+// crosses from its first half to its second, by searching forwards from the
+// start and backwards from the end at once (Myers' middle snake). It
+// returns -1, -1 if the point would not divide the problem, as when a and b
+// have no line in common, or if finding it would cost more than maxCost.
+// a and b must not start or end with the same line.
+func split(a, b []int) (int, int) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return -1, -1
+	}
+
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	// forward[k] and backward[k] hold the furthest x reached on diagonal k,
+	// counted from the start and from the end respectively
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonals that ran off the edge of the grid are skipped from then on
+	var kStart, kEnd, rStart, rEnd int
+	cost := 0
+	for d := 0; d < maxD; d++ {
+		if cost > maxCost {
+			return -1, -1
+		}
+		for k := -d + kStart; k <= d-kEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+				cost++
+			}
+			cost++
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				kEnd += 2
+			case y > m:
+				kStart += 2
+			case odd:
+				if r := offset + delta - k; r >= 0 && r < len(backward) && backward[r] != -1 && x >= n-backward[r] {
+					return divides(x, y, n, m)
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+				cost++
+			}
+			cost++
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd:
+				if f := offset + delta - k; f >= 0 && f < len(forward) && forward[f] != -1 {
+					fx := forward[f]
+					fy := fx - (f - offset)
+					if fx >= n-x {
+						return divides(fx, fy, n, m)
+					}
+				}
+			}
+		}
+	}
+	return -1, -1
+}
+
+// divides returns (x, y) if it splits an n by m problem into two smaller
+// ones, and -1, -1 otherwise
+func divides(x, y, n, m int) (int, int) {
+	if (x == 0 && y == 0) || (x == n && y == m) {
+		return -1, -1
+	}
+	return x, y
+}
+
+// Unified returns the unified diff turning a into b with the given number of
+// context lines, labelled with the two names. It is empty if a equals b.
+func Unified(oldName, newName, a, b string, context int) string {
+	return Format(oldName, newName, Compute(a, b), context)
+}
+
+// Format renders edits from Compute as a unified diff with the given number
+// of context lines, labelled with the two names. It is empty if there are no
+// changes.
+func Format(oldName, newName string, edits []Edit, context int) string {
+	var out strings.Builder
+	for _, h := range hunks(edits, context) {
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(h.oldStart, h.oldLines), hunkRange(h.newStart, h.newLines))
+		for _, e := range edits[h.from:h.to] {
+			out.WriteByte(byte(e.Op))
+			out.WriteString(e.Text)
+			if !strings.HasSuffix(e.Text, "\n") {
+				out.WriteString("\n" + noNewline + "\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+type hunk struct {
+	from, to           int // Range of edits
+	oldStart, oldLines int
+	newStart, newLines int
+}
+
+// hunks groups changes that are at most 2*context lines apart
+func hunks(edits []Edit, context int) []hunk {
+	var result []hunk
+	oldLine, newLine := 0, 0 // Lines consumed before edits[i]
+	lineAt := make([][2]int, len(edits)+1)
+	for i, e := range edits {
+		lineAt[i] = [2]int{oldLine, newLine}
+		if e.Op != Insert {
+			oldLine++
+		}
+		if e.Op != Delete {
+			newLine++
+		}
+	}
+	lineAt[len(edits)] = [2]int{oldLine, newLine}
+
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+
+		from := i - context
+		if from < 0 {
+			from = 0
+		}
+
+		// Extend past changes until a run of unchanged lines is long enough
+		// to end the hunk
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].Op == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				break
+			}
+			end = run
+		}
+		to := end + context
+		if to > len(edits) {
+			to = len(edits)
+		}
+
+		h := hunk{
+			from:     from,
+			to:       to,
+			oldStart: lineAt[from][0] + 1,
+			newStart: lineAt[from][1] + 1,
+			oldLines: lineAt[to][0] - lineAt[from][0],
+			newLines: lineAt[to][1] - lineAt[from][1],
+		}
+		result = append(result, h)
+		i = to
+	}
+	return result
+}
+
+// hunkRange formats a hunk's line range; an empty range starts at the line
+// before it
+func hunkRange(start, lines int) string {
+	if lines == 0 {
+		start--
+	}
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
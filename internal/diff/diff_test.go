@@ -0,0 +1,292 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lcs returns the length of the longest common subsequence of lines, which
+// fixes the size of a shortest edit script
+func lcs(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else if prev[j+1] > cur[j] {
+				cur[j+1] = prev[j+1]
+			} else {
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// sides rebuilds the two texts an edit script connects
+func sides(edits []Edit) (string, string) {
+	var a, b strings.Builder
+	for _, e := range edits {
+		if e.Op != Insert {
+			a.WriteString(e.Text)
+		}
+		if e.Op != Delete {
+			b.WriteString(e.Text)
+		}
+	}
+	return a.String(), b.String()
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"", ""},
+		{"", "a\nb\n"},
+		{"a\nb\n", ""},
+		{"a\nb\nc\n", "a\nb\nc\n"},
+		{"a\nb\nc\n", "a\nx\nc\n"},
+		{"a\nb\nc\n", "c\nb\na\n"},
+		{"a\nb", "a\nb\n"},
+		{"a\nb\nc\nd\n", "x\ny\n"},
+		{"a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n"},
+	}
+
+	// And random texts over a small alphabet, so lines repeat
+	r := rand.New(rand.NewSource(1))
+	random := func() string {
+		var s strings.Builder
+		for i := r.Intn(30); i > 0; i-- {
+			s.WriteString(string(rune('a'+r.Intn(4))) + "\n")
+		}
+		return s.String()
+	}
+	for i := 0; i < 500; i++ {
+		tests = append(tests, struct{ a, b string }{random(), random()})
+	}
+
+	for _, tt := range tests {
+		edits := Compute(tt.a, tt.b)
+		if a, b := sides(edits); a != tt.a || b != tt.b {
+			t.Fatalf("Compute(%q, %q) connects %q and %q", tt.a, tt.b, a, b)
+		}
+		x, y := Lines(tt.a), Lines(tt.b)
+		added, removed := Stats(edits)
+		if want := len(x) + len(y) - 2*lcs(x, y); added+removed != want {
+			t.Errorf("Compute(%q, %q) has %d changes, want %d", tt.a, tt.b, added+removed, want)
+		}
+	}
+}
+
+func TestComputeLarge(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 40000; i++ {
+		fmt.Fprintf(&a, "line %d\n", i)
+		fmt.Fprintf(&b, "line %d\n", i*7%40000)
+	}
+
+	start := time.Now()
+	if edits := Compute("", a.String()); len(edits) != 40000 {
+		t.Errorf("new file: %d edits, want 40000", len(edits))
+	}
+	edits := Compute(a.String(), b.String())
+	if x, y := sides(edits); x != a.String() || y != b.String() {
+		t.Error("large diff does not connect its inputs")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("large diffs took %v", elapsed)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", 3, ""},
+		{
+			"change", "a\nb\nc\n", "a\nx\nc\n", 3,
+			"--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			"new file", "", "a\nb\n", 3,
+			"--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"deleted file", "a\n", "", 3,
+			"--- a\n+++ b\n@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			"no newline", "a\nb", "a\nc", 3,
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			"two hunks", "1\n2\n3\n4\n5\n6\n7\n8\n", "x\n2\n3\n4\n5\n6\n7\ny\n", 1,
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+y\n",
+		},
+		{
+			"joined hunks", "1\n2\n3\n4\n", "x\n2\n3\ny\n", 1,
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n-4\n+y\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := Unified("a", "b", tt.a, tt.b, tt.context); got != tt.want {
+			t.Errorf("%s: Unified() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		patch    string
+		want     string
+		conflict bool
+	}{
+		{
+			name:  "change",
+			text:  "a\nb\nc\n",
+			patch: "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+			want:  "a\nx\nc\n",
+		},
+		{
+			name:  "shifted",
+			text:  "0\n0\na\nb\nc\n",
+			patch: "@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+			want:  "0\n0\na\nx\nc\n",
+		},
+		{
+			name:  "wrong counts",
+			text:  "a\nb\nc\n",
+			patch: "@@ -1,9 +1,9 @@\n a\n-b\n+x\n",
+			want:  "a\nx\nc\n",
+		},
+		{
+			name:  "insert at start",
+			text:  "a\n",
+			patch: "@@ -0,0 +1 @@\n+x\n",
+			want:  "x\na\n",
+		},
+		{
+			name:  "append",
+			text:  "a\n",
+			patch: "@@ -1,0 +2 @@\n+b\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:  "new file",
+			text:  "",
+			patch: "--- /dev/null\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:  "lost blank context",
+			text:  "a\n\nb\n",
+			patch: "@@ -1,3 +1,3 @@\n a\n\n-b\n+c\n",
+			want:  "a\n\nc\n",
+		},
+		{
+			name:  "keeps missing newline",
+			text:  "a\nb",
+			patch: "@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			want:  "a\nc",
+		},
+		{
+			name:  "adds newline",
+			text:  "a\nb",
+			patch: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:  "after missing newline",
+			text:  "a",
+			patch: "@@ -1 +1,2 @@\n-a\n\\ No newline at end of file\n+a\n+b\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:     "conflict",
+			text:     "a\nb\nc\n",
+			patch:    "@@ -1,3 +1,3 @@\n a\n-q\n+x\n c\n",
+			conflict: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Apply(tt.text, tt.patch)
+		var conflict *ConflictError
+		switch {
+		case tt.conflict:
+			if !errors.As(err, &conflict) {
+				t.Errorf("%s: Apply() error = %v, want a ConflictError", tt.name, err)
+			}
+		case err != nil:
+			t.Errorf("%s: Apply() error = %v", tt.name, err)
+		case got != tt.want:
+			t.Errorf("%s: Apply() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	for _, patch := range []string{
+		"",
+		"just text\n",
+		"@@ -x +1 @@\n+a\n",
+		"@@ -1 +1 @@\n?a\n",
+		"--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n--- a/g\n+++ b/g\n@@ -1 +1 @@\n-a\n+b\n",
+	} {
+		if _, err := Apply("a\n", patch); err == nil {
+			t.Errorf("Apply(%q) succeeded, want an error", patch)
+		}
+	}
+}
+
+// Applying the diff of two texts to the first gives the second
+func TestApplyUnified(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	random := func() string {
+		var s strings.Builder
+		for i := r.Intn(40); i > 0; i-- {
+			s.WriteString(string(rune('a'+r.Intn(5))) + "\n")
+		}
+		if r.Intn(4) == 0 {
+			s.WriteString("end")
+		}
+		return s.String()
+	}
+	for i := 0; i < 300; i++ {
+		a, b := random(), random()
+		patch := Unified("a", "b", a, b, r.Intn(4))
+		if patch == "" {
+			continue
+		}
+		got, err := Apply(a, patch)
+		if err != nil || got != b {
+			t.Fatalf("Apply(%q, Unified to %q) = %q, %v\n%s", a, b, got, err, patch)
+		}
+	}
+}
+
+func TestApplyLarge(t *testing.T) {
+	var text strings.Builder
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&text, "line %d\n", i)
+	}
+	start := time.Now()
+	got, err := Apply(text.String(), "@@ -25000 +25000 @@\n-line 24999\n+changed\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "line 24998\nchanged\nline 25000\n") {
+		t.Error("the change was not applied")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Apply on a large file took %v", elapsed)
+	}
+}
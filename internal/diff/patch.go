@@ -0,0 +1,189 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Hunk is one hunk of a unified diff
+type Hunk struct {
+	OldStart int // 1-based line where the hunk applies, per its header
+	Lines    []Edit
+
+	noNewlineOld bool // The old side ends without a newline
+	noNewlineNew bool // The new side ends without a newline
+}
+
+// Parse reads the hunks of a unified diff for a single file. File headers
+// are skipped, and the line counts in hunk headers are not trusted, since
+// hand-written diffs often get them wrong.
+func Parse(patch string) ([]Hunk, error) {
+	var (
+		result  []Hunk
+		current *Hunk
+		files   int
+	)
+	lines := strings.Split(strings.TrimRight(patch, "\n"), "\n")
+	for n, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q", n+1, line)
+			}
+			start, _ := strconv.Atoi(m[1])
+			result = append(result, Hunk{OldStart: start})
+			current = &result[len(result)-1]
+
+		case strings.HasPrefix(line, "--- ") && n+1 < len(lines) && strings.HasPrefix(lines[n+1], "+++ "):
+			files++
+			if files > 1 {
+				return nil, fmt.Errorf("line %d: the diff changes more than one file", n+1)
+			}
+			current = nil
+
+		case current == nil:
+			// "diff --git", "index", "+++" and other preamble
+
+		case line == noNewline || strings.HasPrefix(line, `\ `):
+			if len(current.Lines) > 0 {
+				last := current.Lines[len(current.Lines)-1]
+				last.Text = strings.TrimSuffix(last.Text, "\n")
+				current.Lines[len(current.Lines)-1] = last
+				if last.Op != Insert {
+					current.noNewlineOld = true
+				}
+				if last.Op != Delete {
+					current.noNewlineNew = true
+				}
+			}
+
+		case line == "":
+			// An empty context line whose leading space was lost
+			current.Lines = append(current.Lines, Edit{Equal, "\n"})
+
+		case isHunkLine(line):
+			current.Lines = append(current.Lines, Edit{Op(line[0]), line[1:] + "\n"})
+
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q inside a hunk", n+1, line)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no hunks found in the diff")
+	}
+	return result, nil
+}
+
+func isHunkLine(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '-' || line[0] == '+')
+}
+
+// ConflictError reports a hunk whose context or removed lines are not in
+// the text being patched
+type ConflictError struct {
+	Hunk     int // 1-based
+	OldStart int
+	Expected string // The lines the hunk expected to find
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("hunk %d (at line %d) does not apply: these lines were not found:\n%s",
+		e.Hunk, e.OldStart, strings.TrimRight(e.Expected, "\n"))
+}
+
+// Apply applies a unified diff to text. Each hunk is located by its content,
+// searching outward from the line its header names, so diffs against a
+// slightly different version still apply. If any hunk does not apply, text
+// is left alone and a *ConflictError is returned.
+func Apply(text, patch string) (string, error) {
+	hunks, err := Parse(patch)
+	if err != nil {
+		return "", err
+	}
+
+	lines := Lines(text)
+	var out []string
+	pos := 0    // Next unconsumed line of text
+	offset := 0 // Drift between header line numbers and actual positions
+
+	for i, h := range hunks {
+		var old, replacement []string
+		for _, e := range h.Lines {
+			if e.Op != Insert {
+				old = append(old, e.Text)
+			}
+			if e.Op != Delete {
+				replacement = append(replacement, e.Text)
+			}
+		}
+		base := h.OldStart - 1
+		if len(old) == 0 {
+			base = h.OldStart // "-N,0" inserts after line N
+		}
+		at, ok := find(lines, old, pos, base+offset)
+		if !ok {
+			return "", &ConflictError{Hunk: i + 1, OldStart: h.OldStart, Expected: strings.Join(old, "")}
+		}
+
+		end := at + len(old)
+		if end == len(lines) && end > 0 && !strings.HasSuffix(lines[end-1], "\n") &&
+			!h.noNewlineOld && !h.noNewlineNew && len(replacement) > 0 {
+			// The diff ignores the missing newline at the end of the file;
+			// keep the file that way
+			last := len(replacement) - 1
+			replacement[last] = strings.TrimSuffix(replacement[last], "\n")
+		}
+
+		out = append(out, lines[pos:at]...)
+		out = append(out, replacement...)
+		pos = end
+		offset = at - base
+	}
+	out = append(out, lines[pos:]...)
+
+	// Lines inserted after a final line without a newline need one between
+	var result strings.Builder
+	for i, line := range out {
+		if i > 0 && !strings.HasSuffix(out[i-1], "\n") {
+			result.WriteByte('\n')
+		}
+		result.WriteString(line)
+	}
+	return result.String(), nil
+}
+
+// find locates block in lines at or after from, preferring the position
+// nearest to want
+func find(lines, block []string, from, want int) (int, bool) {
+	last := len(lines) - len(block)
+	if want < from {
+		want = from
+	}
+	if want > last {
+		want = last
+	}
+	for delta := 0; want-delta >= from || want+delta <= last; delta++ {
+		for _, at := range []int{want - delta, want + delta} {
+			if at >= from && at <= last && matches(lines[at:at+len(block)], block) {
+				return at, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func matches(lines, block []string) bool {
+	for i := range block {
+		if strings.TrimSuffix(lines[i], "\n") != strings.TrimSuffix(block[i], "\n") {
+			return false
+		}
+	}
+	return true
+}
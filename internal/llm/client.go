@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/azhany/codecli/internal/config"
 )
@@ -26,73 +28,299 @@ func NewClient() (*Client, error) {
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"` // Tool that produced a RoleTool message
+}
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// ToolCall is a request from the model to run a tool
+type ToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+// ToolDefinition describes a tool the model may call. Parameters is a JSON
+// Schema object describing the tool's arguments.
+type ToolDefinition struct {
+	Type     string `json:"type"` // Always "function"
+	Function struct {
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		Parameters  interface{} `json:"parameters"`
+	} `json:"function"`
+}
+
+// NewToolDefinition creates a function tool definition
+func NewToolDefinition(name, description string, parameters interface{}) ToolDefinition {
+	def := ToolDefinition{Type: "function"}
+	def.Function.Name = name
+	def.Function.Description = description
+	def.Function.Parameters = parameters
+	return def
 }
 
 type ChatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model    string           `json:"model"`
+	Messages []Message        `json:"messages"`
+	Tools    []ToolDefinition `json:"tools,omitempty"`
+	Stream   bool             `json:"stream"`
 }
 
+// ChatResponse is a complete response, or one chunk of a streamed response
 type ChatResponse struct {
-	Message struct {
-		Content string `json:"content"`
-	} `json:"message"`
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// GenerateRequest asks for a completion of Prompt. With Suffix set, the
+// model fills in the text between the two, if its template supports it.
+type GenerateRequest struct {
+	Model   string           `json:"model"`
+	Prompt  string           `json:"prompt"`
+	Suffix  string           `json:"suffix,omitempty"`
+	Stream  bool             `json:"stream"`
+	Options *GenerateOptions `json:"options,omitempty"`
+}
+
+// GenerateOptions are the sampling options of a GenerateRequest
+type GenerateOptions struct {
+	NumPredict  int      `json:"num_predict,omitempty"` // Maximum tokens to generate
+	Temperature float64  `json:"temperature"`
+	Stop        []string `json:"stop,omitempty"` // Generation ends before any of these
+}
+
+type GenerateResponse struct {
+	Response   string `json:"response"`
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"` // "stop" or "length"
+	Error      string `json:"error,omitempty"`
+}
+
+type ModelsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
 }
 
 type EmbeddingsRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
+	Model string   `json:"model"`
+	Input []string `json:"input"`
 }
 
 type EmbeddingsResponse struct {
 	Embeddings [][]float32 `json:"embeddings"`
 }
 
-// Chat sends a message to the LLM and processes the response
-func (c *Client) Chat(ctx context.Context, message string, tools []string) (string, error) {
+// Chat sends a conversation to the LLM and returns its reply, which may
+// request tool calls instead of or in addition to content. An empty model
+// selects ollama.chat_model.
+func (c *Client) Chat(ctx context.Context, model string, messages []Message, tools []ToolDefinition) (Message, error) {
+	resp, err := c.postChat(ctx, model, messages, tools, false)
+	if err != nil {
+		return Message{}, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return Message{}, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if chatResp.Error != "" {
+		return Message{}, fmt.Errorf("model error: %s", chatResp.Error)
+	}
+
+	return chatResp.Message, nil
+}
+
+// ChatStream is like Chat but streams the reply, calling onToken with each
+// piece of content as it arrives. If ctx is cancelled mid-stream the partial
+// reply is returned along with the context's error.
+func (c *Client) ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDefinition, onToken func(string)) (Message, error) {
+	reply := Message{Role: RoleAssistant}
+
+	resp, err := c.postChat(ctx, model, messages, tools, true)
+	if err != nil {
+		return reply, err
+	}
+	defer resp.Body.Close()
+
+	// The response is a stream of newline-delimited JSON objects
+	var content strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ChatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			reply.Content = content.String()
+			if ctx.Err() != nil {
+				return reply, ctx.Err()
+			}
+			if err == io.EOF {
+				return reply, nil
+			}
+			return reply, fmt.Errorf("failed to decode response: %v", err)
+		}
+		if chunk.Error != "" {
+			reply.Content = content.String()
+			return reply, fmt.Errorf("model error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if onToken != nil {
+				onToken(chunk.Message.Content)
+			}
+		}
+		reply.ToolCalls = append(reply.ToolCalls, chunk.Message.ToolCalls...)
+		if chunk.Done {
+			reply.Content = content.String()
+			return reply, nil
+		}
+	}
+}
+
+func (c *Client) postChat(ctx context.Context, model string, messages []Message, tools []ToolDefinition, stream bool) (*http.Response, error) {
+	if model == "" {
+		model = config.Config.Ollama.ChatModel
+	}
 	reqBody := ChatRequest{
-		Model: config.Config.Ollama.ChatModel,
-		Messages: []Message{
-			{Role: "user", Content: message},
-		},
+		Model:    model,
+		Messages: messages,
+		Tools:    tools,
+		Stream:   stream,
 	}
 
 	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewReader(reqBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		// Ollama explains failures such as an unknown model in the body
+		var errResp ChatResponse
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, errResp.Error)
+		}
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// Generate completes a prompt with the given model, by default
+// ollama.code_model, and returns the generated text
+func (c *Client) Generate(ctx context.Context, req GenerateRequest) (GenerateResponse, error) {
+	if req.Model == "" {
+		req.Model = config.Config.Ollama.CodeModel
+	}
+	req.Stream = false
+
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return GenerateResponse{}, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/generate", bytes.NewReader(reqBytes))
+	if err != nil {
+		return GenerateResponse{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return GenerateResponse{}, ctx.Err()
+		}
+		return GenerateResponse{}, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	var genResp GenerateResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&genResp)
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		// Ollama explains failures such as a model without infill support
+		if decodeErr == nil && genResp.Error != "" {
+			return GenerateResponse{}, fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, genResp.Error)
+		}
+		return GenerateResponse{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return GenerateResponse{}, fmt.Errorf("failed to decode response: %v", decodeErr)
 	}
+	if genResp.Error != "" {
+		return GenerateResponse{}, fmt.Errorf("model error: %s", genResp.Error)
+	}
+	return genResp, nil
+}
 
-	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %v", err)
+// ListModels returns the names of the models available on the Ollama server
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	return chatResp.Message.Content, nil
+	var modelsResp ModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	names := make([]string, 0, len(modelsResp.Models))
+	for _, model := range modelsResp.Models {
+		names = append(names, model.Name)
+	}
+	return names, nil
 }
 
 // EmbedText generates embeddings for text
 func (c *Client) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for several texts in a single request.
+// The returned slice is parallel to texts.
+func (c *Client) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
 	reqBody := EmbeddingsRequest{
-		Model:  config.Config.Ollama.EmbeddingModel,
-		Prompt: text,
+		Model: config.Config.Ollama.EmbeddingModel,
+		Input: texts,
 	}
 
 	reqBytes, err := json.Marshal(reqBody)
@@ -100,7 +328,7 @@ func (c *Client) EmbedText(ctx context.Context, text string) ([]float32, error)
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/embeddings", bytes.NewReader(reqBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/embed", bytes.NewReader(reqBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
@@ -121,9 +349,14 @@ func (c *Client) EmbedText(ctx context.Context, text string) ([]float32, error)
 		return nil, fmt.Errorf("failed to decode response: %v", err)
 	}
 
-	if len(embedResp.Embeddings) == 0 || len(embedResp.Embeddings[0]) == 0 {
-		return nil, fmt.Errorf("empty embeddings in response")
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings in response, got %d", len(texts), len(embedResp.Embeddings))
+	}
+	for _, embedding := range embedResp.Embeddings {
+		if len(embedding) == 0 {
+			return nil, fmt.Errorf("empty embeddings in response")
+		}
 	}
 
-	return embedResp.Embeddings[0], nil
+	return embedResp.Embeddings, nil
 }
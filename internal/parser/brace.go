@@ -0,0 +1,718 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BraceParser extracts definitions from C-family languages (JavaScript,
+// TypeScript, Java, C/C++, PHP) heuristically. It scans the source for
+// braces outside strings and comments and classifies the text leading up
+// to each block or statement. Only declarations directly in a file, class
+// or namespace are reported; the bodies of functions are skipped.
+type BraceParser struct {
+	Language string
+}
+
+var (
+	containerRe   = regexp.MustCompile(`\b(class|interface|struct|union|enum|trait|record|namespace|module)\s+([A-Za-z_$][\w$]*(?:(?:::|\\|\.)[A-Za-z_$][\w$]*)*)`)
+	enumClassRe   = regexp.MustCompile(`\benum\s+(?:class|struct)\b`)
+	functionRe    = regexp.MustCompile(`\bfunction\s*\*?\s*&?\s*([A-Za-z_$][\w$]*)\s*(?:<[^>]*>\s*)?\(`)
+	arrowRe       = regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|(?:<[^>]*>\s*)?\([^)]*\)\s*(?::[^=]+)?=>|[A-Za-z_$][\w$]*\s*=>)`)
+	memberArrowRe = regexp.MustCompile(`^(?:(?:public|private|protected|static|readonly|override)\s+)*#?([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|(?:<[^>]*>\s*)?\([^)]*\)\s*(?::[^=]+)?=>|[A-Za-z_$][\w$]*\s*=>)`)
+	callableRe    = regexp.MustCompile(`([A-Za-z_$~][\w$]*(?:<[^<>()]*>)?(?:::~?[A-Za-z_$][\w$]*(?:<[^<>()]*>)?)*)\s*\(`)
+	typeArgsRe    = regexp.MustCompile(`<[^<>]*>`)
+	accessLabelRe = regexp.MustCompile(`^(?:public|protected|private|signals|slots|Q_SLOTS|Q_SIGNALS)(?:\s+(?:slots|Q_SLOTS))?$`)
+	keywordRe     = regexp.MustCompile(`^(if|else|for|foreach|while|do|switch|case|catch|try|return|throw|new|delete|sizeof|typeof|instanceof|function|super|this|await|yield|with|synchronized|using|elseif|isset|empty|unset|array|list|declare|assert|defined|alignof|decltype|static_assert)$`)
+
+	jsDeclRe     = regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?(const|let|var)\s+([A-Za-z_$][\w$]*)`)
+	tsTypeRe     = regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?type\s+([A-Za-z_$][\w$]*)`)
+	javaConstRe  = regexp.MustCompile(`\b(?:static\s+final|final\s+static)\b[^=(]*?\b([A-Za-z_]\w*)\s*=`)
+	cConstRe     = regexp.MustCompile(`^[^=(]*\b(?:const|constexpr)\b[^=(]*?\b([A-Za-z_]\w*)\s*(?:\[[^\]]*\]\s*)*=`)
+	cUsingRe     = regexp.MustCompile(`^using\s+([A-Za-z_]\w*)\s*=`)
+	funcPtrRe    = regexp.MustCompile(`\(\s*\*\s*([A-Za-z_]\w*)\s*\)`)
+	defineRe     = regexp.MustCompile(`^#\s*define\s+([A-Za-z_]\w*)(\()?`)
+	phpConstRe   = regexp.MustCompile(`^(?:(?:public|protected|private|final)\s+)*const\s+(?:[\w\\|?]+\s+)?([A-Za-z_]\w*)\s*=`)
+	phpDefineRe  = regexp.MustCompile(`^define\s*\(\s*['"]([A-Za-z_]\w*)['"]`)
+	externCRe    = regexp.MustCompile(`^extern\s+"C(?:\+\+)?"$`)
+	identifierRe = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+)
+
+// maxHeader caps the text kept for classifying a declaration
+const maxHeader = 4096
+
+type frameKind int
+
+const (
+	frameBody      frameKind = iota // Function body or other block; not scanned for definitions
+	frameContainer                  // Class, struct, interface, enum or trait
+	frameNamespace                  // Namespace, module or extern "C" block
+)
+
+type braceFrame struct {
+	kind frameKind
+	name string
+	def  int // Index of the definition the block belongs to, or -1
+}
+
+// braceScan holds the state of a BraceParser run
+type braceScan struct {
+	lang  string
+	src   string
+	lines []int // Offsets of line starts
+
+	defs   []Definition
+	frames []braceFrame
+
+	header      strings.Builder // Text of the declaration being read
+	headerStart int             // Offset where it starts, or -1
+	parens      int             // Open ( and [ in the header
+	nested      int             // Braces open inside those parens
+	pending     int             // Definition whose statement ends at the next ';', or -1
+}
+
+func (p BraceParser) Parse(content string) ([]Definition, error) {
+	s := &braceScan{lang: p.Language, src: content, headerStart: -1, pending: -1}
+	s.lines = append(s.lines, 0)
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+	s.scan()
+
+	// A typedef whose name could not be found
+	defs := s.defs[:0]
+	for _, def := range s.defs {
+		if def.Name != "" {
+			defs = append(defs, def)
+		}
+	}
+	sortDefinitions(defs)
+	return defs, nil
+}
+
+// line returns the 1-based line of an offset
+func (s *braceScan) line(offset int) int {
+	return sort.SearchInts(s.lines, offset+1)
+}
+
+func (s *braceScan) scan() {
+	src := s.src
+	i := 0
+	if s.lang == "php" {
+		i = s.skipHTML(0)
+	}
+
+	for ; i < len(src); i++ {
+		c := src[i]
+		var next byte
+		if i+1 < len(src) {
+			next = src[i+1]
+		}
+
+		switch {
+		case c == '\n':
+			s.newline(i)
+		case c == '/' && next == '/', c == '#' && i == 0 && next == '!',
+			c == '#' && s.lang == "php" && next != '[':
+			i = lineEnd(src, i) - 1
+		case c == '/' && next == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return
+			}
+			i += end + 3
+			s.text(" ")
+		case c == '?' && next == '>' && s.lang == "php":
+			i = s.skipHTML(i+2) - 1
+			s.reset()
+		case c == '#' && (s.lang == "c" || s.lang == "cpp") && atLineStart(src, i):
+			i = s.directive(i) - 1
+		case c == '"' || c == '\'' || c == '`':
+			end := s.stringEnd(i)
+			s.text(src[i:end])
+			i = end - 1
+		case c == '{':
+			s.open(i)
+		case c == '}':
+			s.close(i)
+		case c == ';':
+			s.semicolon(i)
+		case c == '(' || c == '[':
+			if s.definitionLevel() {
+				s.parens++
+				s.textAt(i, string(c))
+			}
+		case c == ')' || c == ']':
+			if s.definitionLevel() {
+				if s.parens > 0 {
+					s.parens--
+				}
+				s.text(string(c))
+			}
+		case c == ':' && s.lang == "cpp" && next != ':' && (i == 0 || src[i-1] != ':') &&
+			s.definitionLevel() && accessLabelRe.MatchString(strings.TrimSpace(s.header.String())):
+			s.reset() // An access specifier such as "public:"
+		case c == ' ' || c == '\t' || c == '\r':
+			s.text(" ")
+		default:
+			s.textAt(i, string(c))
+		}
+	}
+
+	// Blocks left open run to the end of the file
+	for _, f := range s.frames {
+		if f.def >= 0 {
+			s.defs[f.def].EndLine = len(s.lines)
+		}
+	}
+}
+
+// definitionLevel reports whether the scanner is directly in the file, a
+// class or a namespace, where declarations are read
+func (s *braceScan) definitionLevel() bool {
+	for _, f := range s.frames {
+		if f.kind == frameBody {
+			return false
+		}
+	}
+	return true
+}
+
+// text adds source text to the declaration being read
+func (s *braceScan) text(t string) {
+	if !s.definitionLevel() || s.header.Len() >= maxHeader {
+		return
+	}
+	if s.headerStart < 0 && strings.TrimSpace(t) == "" {
+		return
+	}
+	s.header.WriteString(t)
+}
+
+// textAt is text for code starting at offset, which may begin a declaration
+func (s *braceScan) textAt(offset int, t string) {
+	if s.definitionLevel() && s.headerStart < 0 {
+		s.headerStart = offset
+	}
+	s.text(t)
+}
+
+func (s *braceScan) reset() {
+	s.header.Reset()
+	s.headerStart = -1
+	s.parens, s.nested = 0, 0
+}
+
+// container returns the innermost class-like frame enclosing the position,
+// or nil if it is in a namespace or the file itself
+func (s *braceScan) container() *braceFrame {
+	if n := len(s.frames); n > 0 && s.frames[n-1].kind == frameContainer {
+		return &s.frames[n-1]
+	}
+	return nil
+}
+
+func (s *braceScan) add(def Definition, start, end int) int {
+	def.StartLine, def.EndLine = s.line(start), s.line(end)
+	if def.Parent == "" {
+		if c := s.container(); c != nil {
+			def.Parent = c.name
+		}
+	}
+	s.defs = append(s.defs, def)
+	return len(s.defs) - 1
+}
+
+func (s *braceScan) open(i int) {
+	if !s.definitionLevel() {
+		s.frames = append(s.frames, braceFrame{kind: frameBody, def: -1})
+		return
+	}
+	if s.parens > 0 {
+		// A brace inside parentheses, e.g. an object in a decorator's
+		// arguments or a callback passed to a top-level call
+		s.nested++
+		s.text("{")
+		return
+	}
+
+	start := s.headerStart
+	if start < 0 {
+		start = i
+	}
+	header := cleanHeader(s.header.String())
+	s.pending = -1
+	frame := s.classifyBlock(header, start, i)
+	s.frames = append(s.frames, frame)
+	s.reset()
+}
+
+func (s *braceScan) close(i int) {
+	if s.definitionLevel() && s.nested > 0 {
+		s.nested--
+		s.text("}")
+		return
+	}
+	if len(s.frames) == 0 {
+		s.reset()
+		return
+	}
+
+	f := s.frames[len(s.frames)-1]
+	s.frames = s.frames[:len(s.frames)-1]
+	if f.def >= 0 {
+		s.defs[f.def].EndLine = s.line(i)
+	}
+	if s.definitionLevel() {
+		s.reset()
+	}
+}
+
+func (s *braceScan) semicolon(i int) {
+	if !s.definitionLevel() {
+		return
+	}
+	if s.parens > 0 {
+		s.text(";")
+		return
+	}
+	s.statement(i)
+}
+
+// statement classifies the declaration read so far as a statement ending
+// at offset i, then starts a new one
+func (s *braceScan) statement(i int) {
+	defer s.reset()
+
+	header := cleanHeader(s.header.String())
+	if s.pending >= 0 {
+		def := &s.defs[s.pending]
+		def.EndLine = s.line(i)
+		if strings.HasPrefix(def.Signature, "typedef") {
+			if name := identifierRe.FindString(header); name != "" {
+				def.Name = name
+			}
+		}
+		s.pending = -1
+		return
+	}
+	if header == "" || s.headerStart < 0 {
+		return
+	}
+	if def, ok := s.classifyStatement(header); ok {
+		s.add(def, s.headerStart, i)
+	}
+}
+
+// newline ends a statement without a semicolon in JavaScript and
+// TypeScript, when neither the line nor the next one continues it
+func (s *braceScan) newline(i int) {
+	if s.lang != "javascript" && s.lang != "typescript" {
+		return
+	}
+	if s.pending >= 0 && s.header.Len() == 0 {
+		// The value ended with its closing brace, without a semicolon
+		s.pending = -1
+	}
+	if !s.definitionLevel() || s.parens > 0 || s.headerStart < 0 {
+		return
+	}
+	header := strings.TrimSpace(s.header.String())
+	if header == "" || continuesAfter(header) || continuesBefore(nextCode(s.src, i+1)) {
+		return
+	}
+	s.statement(i)
+}
+
+// classifyBlock decides what the block opening at offset i is, recording a
+// definition for it if it is one
+func (s *braceScan) classifyBlock(header string, start, i int) braceFrame {
+	frame := braceFrame{kind: frameBody, def: -1}
+	if header == "" {
+		return frame
+	}
+	sig := signature(header)
+
+	switch s.lang {
+	case "c", "cpp":
+		if strings.HasPrefix(header, "typedef") {
+			// typedef struct tag { ... } Name; is named after the brace
+			name := ""
+			if m := containerRe.FindStringSubmatch(header); m != nil {
+				name = m[2]
+			}
+			frame.def = s.add(Definition{Name: name, Kind: KindType, Signature: sig}, start, i)
+			s.pending = frame.def
+			return frame
+		}
+		if externCRe.MatchString(header) {
+			frame.kind = frameNamespace
+			return frame
+		}
+	}
+
+	if m := containerRe.FindStringSubmatchIndex(header); m != nil {
+		keyword, name := header[m[2]:m[3]], header[m[4]:m[5]]
+		// "struct point *make_point(...)" is a function returning a struct
+		isReturnType := (keyword == "struct" || keyword == "union" || keyword == "enum") &&
+			strings.Contains(header[m[1]:], "(")
+		if !isReturnType {
+			if keyword == "namespace" || keyword == "module" {
+				frame.kind = frameNamespace
+				return frame
+			}
+			frame.kind = frameContainer
+			frame.name = name
+			frame.def = s.add(Definition{Name: name, Kind: containerKind(keyword), Signature: sig}, start, i)
+			return frame
+		}
+	}
+
+	if def, ok := s.classifyFunction(header, false); ok {
+		def.Signature = sig
+		frame.def = s.add(def, start, i)
+		return frame
+	}
+
+	// A value with a braced initializer, e.g. const config = { ... }; its
+	// statement ends at the next semicolon
+	if def, ok := s.classifyValue(header); ok {
+		def.Signature = sig
+		frame.def = s.add(def, start, i)
+		s.pending = frame.def
+	}
+	return frame
+}
+
+// classifyStatement decides whether a statement ending in ';' declares
+// something
+func (s *braceScan) classifyStatement(header string) (Definition, bool) {
+	sig := signature(header)
+
+	switch s.lang {
+	case "c", "cpp":
+		if strings.HasPrefix(header, "typedef") {
+			name := ""
+			if m := funcPtrRe.FindStringSubmatch(header); m != nil {
+				name = m[1]
+			} else if ids := identifierRe.FindAllString(header, -1); len(ids) > 1 {
+				name = ids[len(ids)-1]
+			}
+			if name == "" {
+				return Definition{}, false
+			}
+			return Definition{Name: name, Kind: KindType, Signature: sig}, true
+		}
+		if m := cUsingRe.FindStringSubmatch(header); m != nil {
+			return Definition{Name: m[1], Kind: KindType, Signature: sig}, true
+		}
+	}
+
+	if def, ok := s.classifyValue(header); ok {
+		def.Signature = sig
+		return def, true
+	}
+
+	// Prototypes: C declarations, abstract and interface methods
+	if s.container() != nil || s.lang == "c" || s.lang == "cpp" || functionRe.MatchString(header) {
+		if def, ok := s.classifyFunction(header, true); ok {
+			def.Signature = sig
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
+
+// classifyFunction recognises function and method headers. A prototype
+// has no body, so it must have a return type or modifier unless it is
+// declared in a class.
+func (s *braceScan) classifyFunction(header string, prototype bool) (Definition, bool) {
+	kind := KindFunction
+	if s.container() != nil {
+		kind = KindMethod
+	}
+
+	if m := functionRe.FindStringSubmatch(header); m != nil {
+		return Definition{Name: m[1], Kind: kind}, true
+	}
+	if s.lang == "javascript" || s.lang == "typescript" {
+		if m := arrowRe.FindStringSubmatch(header); m != nil {
+			return Definition{Name: m[1], Kind: kind}, true
+		}
+		if s.container() != nil {
+			if m := memberArrowRe.FindStringSubmatch(header); m != nil {
+				return Definition{Name: m[1], Kind: kind}, true
+			}
+		}
+	}
+	if s.lang == "php" {
+		return Definition{}, false // PHP functions always say "function"
+	}
+
+	for _, m := range callableRe.FindAllStringSubmatchIndex(header, -1) {
+		name := typeArgsRe.ReplaceAllString(header[m[2]:m[3]], "")
+		if keywordRe.MatchString(name) {
+			continue
+		}
+		prefix := strings.TrimSpace(header[:m[0]])
+		if strings.ContainsAny(prefix, "=(") || strings.HasSuffix(prefix, ".") || strings.HasSuffix(prefix, "->") {
+			break // A call or an assignment
+		}
+		if strings.HasPrefix(prefix, "return") || strings.HasPrefix(prefix, "new ") {
+			break
+		}
+		if prototype && s.container() == nil && (prefix == "" || strings.HasPrefix(prefix, "extern \"C")) {
+			break // A call, e.g. a macro invocation
+		}
+
+		def := Definition{Name: name, Kind: kind}
+		if idx := strings.LastIndex(name, "::"); idx >= 0 {
+			// Out-of-line C++ definition: Class::method
+			def.Name, def.Parent, def.Kind = name[idx+2:], name[:idx], KindMethod
+		}
+		return def, true
+	}
+	return Definition{}, false
+}
+
+// classifyValue recognises constants, variables and type aliases
+func (s *braceScan) classifyValue(header string) (Definition, bool) {
+	switch s.lang {
+	case "javascript", "typescript":
+		if s.container() != nil {
+			return Definition{}, false
+		}
+		if m := tsTypeRe.FindStringSubmatch(header); m != nil {
+			return Definition{Name: m[1], Kind: KindType}, true
+		}
+		if m := jsDeclRe.FindStringSubmatch(header); m != nil {
+			if m[1] == "const" {
+				return Definition{Name: m[2], Kind: KindConst}, true
+			}
+			return Definition{Name: m[2], Kind: KindVar}, true
+		}
+	case "java":
+		if m := javaConstRe.FindStringSubmatch(header); m != nil {
+			return Definition{Name: m[1], Kind: KindConst}, true
+		}
+	case "c", "cpp":
+		if m := cConstRe.FindStringSubmatch(header); m != nil {
+			return Definition{Name: m[1], Kind: KindConst}, true
+		}
+	case "php":
+		if m := phpConstRe.FindStringSubmatch(header); m != nil {
+			return Definition{Name: m[1], Kind: KindConst}, true
+		}
+		if m := phpDefineRe.FindStringSubmatch(header); m != nil {
+			return Definition{Name: m[1], Kind: KindConst}, true
+		}
+	}
+	return Definition{}, false
+}
+
+// directive reads a C preprocessor line starting at offset i, recording
+// macro definitions, and returns the offset of its end
+func (s *braceScan) directive(i int) int {
+	end := i
+	for {
+		end = lineEnd(s.src, end)
+		if end > i && end < len(s.src) && strings.HasSuffix(strings.TrimRight(s.src[i:end], "\r"), "\\") {
+			end++
+			continue
+		}
+		break
+	}
+
+	if s.definitionLevel() {
+		if m := defineRe.FindStringSubmatch(s.src[i:end]); m != nil {
+			kind := KindConst
+			if m[2] != "" {
+				kind = KindFunction // A function-like macro
+			}
+			line := strings.TrimSpace(s.src[i:lineEnd(s.src, i)])
+			s.add(Definition{Name: m[1], Kind: kind, Signature: signature(strings.TrimSuffix(line, "\\"))}, i, end)
+		}
+	}
+	return end
+}
+
+// skipHTML returns the offset after the next PHP opening tag at or after i
+func (s *braceScan) skipHTML(i int) int {
+	idx := strings.Index(s.src[i:], "<?")
+	if idx < 0 {
+		return len(s.src)
+	}
+	i += idx + 2
+	switch {
+	case strings.HasPrefix(s.src[i:], "php"):
+		i += 3
+	case strings.HasPrefix(s.src[i:], "="):
+		i++
+	}
+	return i
+}
+
+// stringEnd returns the offset after the string literal starting at i.
+// Only template literals and PHP strings span lines.
+func (s *braceScan) stringEnd(i int) int {
+	quote := s.src[i]
+	multiline := quote == '`' || s.lang == "php"
+	for j := i + 1; j < len(s.src); j++ {
+		switch s.src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			if !multiline {
+				return j
+			}
+		}
+	}
+	return len(s.src)
+}
+
+func containerKind(keyword string) string {
+	switch keyword {
+	case "interface", "trait":
+		return KindInterface
+	case "struct", "union":
+		return KindStruct
+	case "enum":
+		return KindEnum
+	default:
+		return KindClass
+	}
+}
+
+// cleanHeader drops decorators, annotations and template parameter lists
+// from the front of a declaration and collapses its whitespace
+func cleanHeader(header string) string {
+	header = strings.Join(strings.Fields(header), " ")
+	for {
+		switch {
+		case strings.HasPrefix(header, "@"):
+			header = strings.TrimSpace(skipAnnotation(header))
+		case strings.HasPrefix(header, "#["):
+			header = strings.TrimSpace(header[balancedEnd(header, 1, '[', ']'):])
+		case strings.HasPrefix(header, "template"):
+			idx := strings.IndexByte(header, '<')
+			if idx < 0 {
+				return header
+			}
+			header = strings.TrimSpace(header[balancedEnd(header, idx, '<', '>'):])
+		default:
+			return enumClassRe.ReplaceAllString(header, "enum")
+		}
+	}
+}
+
+// skipAnnotation returns the text after an annotation such as
+// @Route("/", methods = {"GET"}) at the start of header
+func skipAnnotation(header string) string {
+	i := 1
+	for i < len(header) && (isIdentByte(header[i]) || header[i] == '.') {
+		i++
+	}
+	if i == 1 {
+		return header[1:] // A bare @, e.g. Java's @interface
+	}
+	j := i
+	for j < len(header) && header[j] == ' ' {
+		j++
+	}
+	if j < len(header) && header[j] == '(' {
+		return header[balancedEnd(header, j, '(', ')'):]
+	}
+	return header[i:]
+}
+
+// balancedEnd returns the offset after the bracket matching the one at i
+func balancedEnd(s string, i int, open, close byte) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// continuesAfter reports whether a statement ending in this text must go
+// on to the next line
+func continuesAfter(header string) bool {
+	if strings.ContainsAny(header[len(header)-1:], ",([=+-*/%&|^!~?:.<>") {
+		return true
+	}
+	fields := strings.Fields(header)
+	switch fields[len(fields)-1] {
+	case "export", "default", "async", "static", "public", "private", "protected", "readonly",
+		"abstract", "declare", "extends", "implements", "new", "class", "function", "const", "let", "var":
+		return true
+	}
+	return false
+}
+
+// continuesBefore reports whether a line starting with this text continues
+// the previous statement
+func continuesBefore(line string) bool {
+	if line == "" {
+		return true // End of file
+	}
+	if strings.ContainsAny(line[:1], "{.?:=|&)],+-*/%<>([`") {
+		return true
+	}
+	word := identifierRe.FindString(line)
+	return strings.HasPrefix(line, word) && (word == "extends" || word == "implements" || word == "as")
+}
+
+// nextCode returns the rest of the next line holding code at or after i,
+// skipping blank lines and comments
+func nextCode(src string, i int) string {
+	for i < len(src) {
+		switch {
+		case src[i] == ' ' || src[i] == '\t' || src[i] == '\r' || src[i] == '\n':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			i = lineEnd(src, i)
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return ""
+			}
+			i += end + 4
+		default:
+			return src[i:lineEnd(src, i)]
+		}
+	}
+	return ""
+}
+
+func lineEnd(src string, i int) int {
+	if idx := strings.IndexByte(src[i:], '\n'); idx >= 0 {
+		return i + idx
+	}
+	return len(src)
+}
+
+func atLineStart(src string, i int) bool {
+	for j := i - 1; j >= 0 && src[j] != '\n'; j-- {
+		if src[j] != ' ' && src[j] != '\t' {
+			return false
+		}
+	}
+	return true
+}
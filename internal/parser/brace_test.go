@@ -0,0 +1,72 @@
+package parser
+
+import "testing"
+
+func TestBraceParser(t *testing.T) {
+	tests := []struct {
+		language string
+		source   string
+		want     []string
+	}{
+		{
+			language: "javascript",
+			source:   "import x from 'y';\n\nexport function add(a, b) {\n  return a + b;\n}\n\nconst mul = (a, b) => {\n  return a * b;\n};\n\nclass Foo extends Bar {\n  constructor() {\n    super();\n  }\n\n  get() {\n    return 1;\n  }\n}\n",
+			want: []string{
+				def(KindFunction, "add", 3, 5),
+				def(KindFunction, "mul", 7, 9),
+				def(KindClass, "Foo", 11, 19),
+				def(KindMethod, "Foo.constructor", 12, 14),
+				def(KindMethod, "Foo.get", 16, 18),
+			},
+		},
+		{
+			language: "typescript",
+			source:   "interface Shape {\n  area(): number;\n}\n\ntype ID = string;\n\nenum Color { Red, Green }\n\nexport class Square implements Shape {\n  area(): number {\n    return 1;\n  }\n}\n",
+			want: []string{
+				def(KindInterface, "Shape", 1, 3),
+				def(KindMethod, "Shape.area", 2, 2),
+				def(KindType, "ID", 5, 5),
+				def(KindEnum, "Color", 7, 7),
+				def(KindClass, "Square", 9, 13),
+				def(KindMethod, "Square.area", 10, 12),
+			},
+		},
+		{
+			language: "java",
+			source:   "package a;\n\n@Annot\npublic class S {\n    private int n;\n\n    public int get() {\n        return n;\n    }\n}\n",
+			want:     []string{def(KindClass, "S", 3, 10), def(KindMethod, "S.get", 7, 9)},
+		},
+		{
+			language: "c",
+			source:   "#include <stdio.h>\n#define MAX 10\n\nstruct point {\n    int x;\n};\n\nstatic int add(int a, int b)\n{\n    return a + b;\n}\n",
+			want: []string{
+				def(KindConst, "MAX", 2, 2),
+				def(KindStruct, "point", 4, 6),
+				def(KindFunction, "add", 8, 11),
+			},
+		},
+		{
+			language: "cpp",
+			source:   "namespace ns {\nclass Box {\npublic:\n    int size() const;\n};\n}\n\nint ns::Box::size() const {\n    return 1;\n}\n",
+			want: []string{
+				def(KindClass, "Box", 2, 5),
+				def(KindMethod, "Box.size", 4, 4),
+				def(KindMethod, "ns::Box.size", 8, 10),
+			},
+		},
+		{
+			language: "php",
+			source:   "<?php\nclass User {\n    public function name() {\n        return '}';\n    }\n}\n\nfunction helper($a) {\n    return $a;\n}\n",
+			want: []string{
+				def(KindClass, "User", 2, 6),
+				def(KindMethod, "User.name", 3, 5),
+				def(KindFunction, "helper", 8, 10),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			checkDefinitions(t, BraceParser{Language: tt.language}, tt.source, tt.want)
+		})
+	}
+}
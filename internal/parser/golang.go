@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// GoParser extracts Go declarations with go/parser. Files with syntax errors
+// yield the declarations that could be parsed.
+type GoParser struct{}
+
+func (GoParser) Parse(content string) ([]Definition, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if file == nil {
+		return nil, err
+	}
+
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+	// header returns the source from start up to end, e.g. a function's
+	// signature without its body
+	header := func(start, end token.Pos) string {
+		from, to := fset.Position(start).Offset, fset.Position(end).Offset
+		if from < 0 || to > len(content) || from >= to {
+			return ""
+		}
+		return signature(content[from:to])
+	}
+
+	var defs []Definition
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			def := Definition{
+				Name:      d.Name.Name,
+				Kind:      KindFunction,
+				StartLine: line(d.Pos()),
+				EndLine:   line(d.End()),
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				def.Kind = KindMethod
+				def.Parent = receiverName(d.Recv.List[0].Type)
+			}
+			if d.Body != nil {
+				def.Signature = header(d.Pos(), d.Body.Lbrace)
+			} else {
+				def.Signature = header(d.Pos(), d.End())
+			}
+			defs = append(defs, def)
+
+		case *ast.GenDecl:
+			defs = append(defs, goGenDecl(d, line, header)...)
+		}
+	}
+
+	// Declarations cut short by a syntax error have no valid end
+	for i := range defs {
+		if defs[i].EndLine < defs[i].StartLine {
+			defs[i].EndLine = defs[i].StartLine
+		}
+	}
+	return defs, nil
+}
+
+// goGenDecl returns the types, constants and variables of a declaration,
+// one per name
+func goGenDecl(d *ast.GenDecl, line func(token.Pos) int, header func(start, end token.Pos) string) []Definition {
+	var defs []Definition
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			def := Definition{
+				Name:      s.Name.Name,
+				Kind:      KindType,
+				StartLine: line(s.Pos()),
+				EndLine:   line(s.End()),
+				Signature: "type " + header(s.Pos(), s.End()),
+			}
+			switch t := s.Type.(type) {
+			case *ast.StructType:
+				def.Kind = KindStruct
+				def.Signature = "type " + header(s.Pos(), t.Fields.Opening)
+			case *ast.InterfaceType:
+				def.Kind = KindInterface
+				def.Signature = "type " + header(s.Pos(), t.Methods.Opening)
+			}
+			if d.Tok == token.TYPE && !d.Lparen.IsValid() {
+				def.StartLine = line(d.Pos())
+			}
+			def.Signature = strings.TrimSpace(def.Signature)
+			defs = append(defs, def)
+
+		case *ast.ValueSpec:
+			kind := KindVar
+			if d.Tok == token.CONST {
+				kind = KindConst
+			}
+			for _, name := range s.Names {
+				if name.Name == "_" {
+					continue
+				}
+				defs = append(defs, Definition{
+					Name:      name.Name,
+					Kind:      kind,
+					StartLine: line(s.Pos()),
+					EndLine:   line(s.End()),
+					Signature: d.Tok.String() + " " + header(s.Pos(), s.End()),
+				})
+			}
+		}
+	}
+	return defs
+}
+
+// receiverName returns the type name of a method receiver, without pointer
+// or type parameters
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
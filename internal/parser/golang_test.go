@@ -0,0 +1,61 @@
+package parser
+
+import "testing"
+
+func TestGoParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "types",
+			source: "package p\n\n// T doc\ntype T struct {\n\tx int\n}\n\ntype (\n\tA int\n\tI interface{ M() }\n)\n",
+			want: []string{
+				def(KindStruct, "T", 4, 6),
+				def(KindType, "A", 9, 9),
+				def(KindInterface, "I", 10, 10),
+			},
+		},
+		{
+			name:   "constants and variables",
+			source: "package p\n\nconst (\n\tC1 = 1\n\tC2 = 2\n)\n\nvar a, b, _ = 1, 2, 3\n",
+			want: []string{
+				def(KindConst, "C1", 4, 4),
+				def(KindConst, "C2", 5, 5),
+				def(KindVar, "a", 8, 8),
+				def(KindVar, "b", 8, 8),
+			},
+		},
+		{
+			name:   "functions and methods",
+			source: "package p\n\nfunc (t *T) M(x int) error {\n\treturn nil\n}\n\nfunc (l List[E]) Len() int { return 0 }\n\nfunc F[K comparable](k K) {}\n",
+			want: []string{
+				def(KindMethod, "T.M", 3, 5),
+				def(KindMethod, "List.Len", 7, 7),
+				def(KindFunction, "F", 9, 9),
+			},
+		},
+		{
+			name:   "syntax error keeps what parsed",
+			source: "package p\n\nfunc A() {}\n\nfunc B() {\n\tx :=\n}\n",
+			want:   []string{def(KindFunction, "A", 3, 3), def(KindFunction, "B", 5, 7)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDefinitions(t, GoParser{}, tt.source, tt.want)
+		})
+	}
+}
+
+func TestGoParserSignature(t *testing.T) {
+	defs, err := GoParser{}.Parse("package p\n\nfunc (s *Store) Search(query string,\n\tlimit int) ([]Result, error) {\n\treturn nil, nil\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "func (s *Store) Search(query string, limit int) ([]Result, error)"
+	if len(defs) != 1 || defs[0].Signature != want {
+		t.Errorf("got %+v, want signature %q", defs, want)
+	}
+}
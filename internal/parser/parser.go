@@ -0,0 +1,151 @@
+// Package parser extracts the definitions (functions, methods, types,
+// constants, ...) of source files and their line ranges. Go is parsed with
+// go/parser; other languages are scanned heuristically.
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Definition kinds
+const (
+	KindFunction  = "function"
+	KindMethod    = "method"
+	KindType      = "type"
+	KindStruct    = "struct"
+	KindClass     = "class"
+	KindInterface = "interface"
+	KindEnum      = "enum"
+	KindConst     = "const"
+	KindVar       = "var"
+)
+
+// maxSignature caps the length of a signature
+const maxSignature = 200
+
+// Definition is a named declaration in a source file
+type Definition struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Parent    string `json:"parent,omitempty"`    // Enclosing type or method receiver
+	Signature string `json:"signature,omitempty"` // Declaration up to its body, on one line
+	StartLine int    `json:"start_line"`          // 1-based, inclusive
+	EndLine   int    `json:"end_line"`            // 1-based, inclusive
+}
+
+// QualifiedName returns the name prefixed by its parent, e.g. "Store.Search"
+func (d Definition) QualifiedName() string {
+	if d.Parent == "" {
+		return d.Name
+	}
+	return d.Parent + "." + d.Name
+}
+
+// Parser extracts definitions from source code
+type Parser interface {
+	Parse(content string) ([]Definition, error)
+}
+
+// languages maps language names to their parsers
+var languages = map[string]Parser{
+	"go":         GoParser{},
+	"python":     PythonParser{},
+	"javascript": BraceParser{Language: "javascript"},
+	"typescript": BraceParser{Language: "typescript"},
+	"java":       BraceParser{Language: "java"},
+	"c":          BraceParser{Language: "c"},
+	"cpp":        BraceParser{Language: "cpp"},
+	"php":        BraceParser{Language: "php"},
+}
+
+// extensions maps file extensions to language names
+var extensions = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".jsx":  "javascript",
+	".mjs":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".java": "java",
+	".c":    "c",
+	".h":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".cxx":  "cpp",
+	".hpp":  "cpp",
+	".php":  "php",
+}
+
+// Languages returns the names of the supported languages
+func Languages() []string {
+	var names []string
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LanguageOf returns the language of a file from its extension, or "" if it
+// is not supported
+func LanguageOf(path string) string {
+	return extensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// ForLanguage returns the parser for a language name
+func ForLanguage(language string) (Parser, error) {
+	p, ok := languages[strings.ToLower(language)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language %q (supported: %s)", language, strings.Join(Languages(), ", "))
+	}
+	return p, nil
+}
+
+// ParseFile extracts the definitions of a file. The language is taken from
+// the file's extension unless given.
+func ParseFile(path, language string) ([]Definition, error) {
+	if language == "" {
+		language = LanguageOf(path)
+		if language == "" {
+			return nil, fmt.Errorf("cannot tell the language of %s; supported extensions are %s", path, supportedExtensions())
+		}
+	}
+	p, err := ForLanguage(language)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Parse(string(content))
+}
+
+func supportedExtensions() string {
+	var exts []string
+	for ext := range extensions {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return strings.Join(exts, " ")
+}
+
+// signature collapses whitespace in a declaration header and caps its length
+func signature(header string) string {
+	s := strings.Join(strings.Fields(header), " ")
+	if len(s) > maxSignature {
+		s = s[:maxSignature-3] + "..."
+	}
+	return s
+}
+
+// sortDefinitions orders definitions by position
+func sortDefinitions(defs []Definition) {
+	sort.SliceStable(defs, func(i, j int) bool { return defs[i].StartLine < defs[j].StartLine })
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+// def describes an expected definition as "kind Parent.Name start-end"
+func def(kind, name string, start, end int) string {
+	return fmt.Sprintf("%s %s %d-%d", kind, name, start, end)
+}
+
+// checkDefinitions parses source and compares the definitions found with
+// want, in order
+func checkDefinitions(t *testing.T, p Parser, source string, want []string) {
+	t.Helper()
+	defs, err := p.Parse(source)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	got := make([]string, len(defs))
+	for i, d := range defs {
+		got[i] = def(d.Kind, d.QualifiedName(), d.StartLine, d.EndLine)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("definitions:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestLanguageOf(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"main.go", "go"},
+		{"pkg/App.JAVA", "java"},
+		{"lib.hpp", "cpp"},
+		{"index.tsx", "typescript"},
+		{"script.py", "python"},
+		{"notes.txt", ""},
+		{"Makefile", ""},
+	}
+	for _, tt := range tests {
+		if got := LanguageOf(tt.path); got != tt.want {
+			t.Errorf("LanguageOf(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PythonParser extracts Python classes, functions and methods by
+// indentation, and module-level constants by naming convention
+// (UPPER_CASE = ...)
+type PythonParser struct{}
+
+var (
+	pyDefRe   = regexp.MustCompile(`^(?:async\s+)?def\s+([A-Za-z_]\w*)`)
+	pyClassRe = regexp.MustCompile(`^class\s+([A-Za-z_]\w*)`)
+	pyConstRe = regexp.MustCompile(`^([A-Z][A-Z0-9_]*)\s*(?::[^=]+)?=[^=]`)
+)
+
+// pyBlock is a def or class whose end has not been seen yet
+type pyBlock struct {
+	index  int // Into the definitions
+	indent int
+}
+
+func (PythonParser) Parse(content string) ([]Definition, error) {
+	lines := strings.Split(content, "\n")
+
+	var (
+		defs     []Definition
+		open     []pyBlock
+		lastCode int    // Last non-blank line, 1-based
+		inString string // Delimiter of an open triple-quoted string
+		brackets int    // Open brackets carried over from previous lines
+	)
+
+	// closeBlocks ends the blocks indented at least as deep as indent
+	closeBlocks := func(indent int) {
+		for len(open) > 0 && open[len(open)-1].indent >= indent {
+			defs[open[len(open)-1].index].EndLine = lastCode
+			open = open[:len(open)-1]
+		}
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if inString != "" {
+			if strings.Count(line, inString)%2 == 1 {
+				inString = ""
+			}
+			lastCode = i + 1
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		continued := brackets > 0
+		brackets += pyBracketDelta(line)
+		if brackets < 0 {
+			brackets = 0
+		}
+		if q := openTripleQuote(line); q != "" {
+			inString = q
+		}
+		if continued {
+			lastCode = i + 1
+			continue
+		}
+
+		indent := indentOf(line)
+		closeBlocks(indent)
+		lastCode = i + 1
+
+		var parent *Definition
+		if len(open) > 0 {
+			parent = &defs[open[len(open)-1].index]
+		}
+
+		def := Definition{StartLine: i + 1, EndLine: i + 1}
+		if m := pyClassRe.FindStringSubmatch(trimmed); m != nil {
+			def.Name, def.Kind = m[1], KindClass
+		} else if m := pyDefRe.FindStringSubmatch(trimmed); m != nil {
+			def.Name, def.Kind = m[1], KindFunction
+			if parent != nil && parent.Kind == KindClass {
+				def.Kind = KindMethod
+			}
+		} else if m := pyConstRe.FindStringSubmatch(trimmed); m != nil && indent == 0 {
+			def.Name, def.Kind = m[1], KindConst
+			def.Signature = signature(trimmed)
+			defs = append(defs, def)
+			continue
+		} else {
+			continue
+		}
+
+		if parent != nil {
+			def.Parent = parent.Name
+		}
+		def.Signature = pyHeader(lines, i)
+		defs = append(defs, def)
+		open = append(open, pyBlock{index: len(defs) - 1, indent: indent})
+	}
+	closeBlocks(0)
+
+	// Constants spanning several lines end where the next statement begins
+	for i := range defs {
+		if defs[i].Kind == KindConst {
+			defs[i].EndLine = pyStatementEnd(lines, defs[i].StartLine-1)
+		}
+	}
+	return defs, nil
+}
+
+// pyHeader returns a def or class header starting at lines[start], up to
+// the colon that opens its body: the first one outside brackets, strings
+// and comments, even when the body follows on the same line
+func pyHeader(lines []string, start int) string {
+	var header strings.Builder
+	depth := 0
+	var quote byte
+	for i := start; i < len(lines) && i < start+20; i++ {
+		line := strings.TrimSpace(lines[i])
+		if header.Len() > 0 {
+			header.WriteByte(' ')
+		}
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			switch {
+			case quote != 0:
+				if c == '\\' && j+1 < len(line) {
+					header.WriteByte(c)
+					j++
+					c = line[j]
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '#':
+				j = len(line)
+				continue
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '(' || c == '[' || c == '{':
+				depth++
+			case c == ')' || c == ']' || c == '}':
+				depth--
+			case c == ':' && depth <= 0:
+				return signature(strings.TrimSpace(header.String()))
+			}
+			header.WriteByte(c)
+		}
+		// Strings do not continue past the end of a line
+		quote = 0
+	}
+	return signature(strings.TrimSpace(header.String()))
+}
+
+// pyStatementEnd returns the 1-based last line of the statement starting at
+// lines[start], following open brackets
+func pyStatementEnd(lines []string, start int) int {
+	depth := 0
+	for i := start; i < len(lines); i++ {
+		depth += pyBracketDelta(lines[i])
+		if depth <= 0 {
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+// pyBracketDelta counts the brackets a line opens minus those it closes,
+// outside strings and comments
+func pyBracketDelta(line string) int {
+	delta := 0
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '#':
+			return delta
+		case c == '"' || c == '\'':
+			if strings.HasPrefix(line[i:], `"""`) || strings.HasPrefix(line[i:], `'''`) {
+				// Triple-quoted strings are tracked by the caller; skip the
+				// rest of the line if this one does not close on it
+				end := strings.Index(line[i+3:], line[i:i+3])
+				if end < 0 {
+					return delta
+				}
+				i += end + 5
+				continue
+			}
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			delta++
+		case c == ')' || c == ']' || c == '}':
+			delta--
+		}
+	}
+	return delta
+}
+
+// openTripleQuote returns the delimiter of a triple-quoted string left open
+// at the end of the line
+func openTripleQuote(line string) string {
+	for _, q := range []string{`"""`, `'''`} {
+		if strings.Count(line, q)%2 == 1 {
+			return q
+		}
+	}
+	return ""
+}
+
+func indentOf(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+package parser
+
+import "testing"
+
+func TestPythonParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "functions and constants",
+			source: "import os\n\nMAX = 3\nlower = 4\n\n@dec\ndef f(a,\n      b: int) -> int:\n    return a\n",
+			want:   []string{def(KindConst, "MAX", 3, 3), def(KindFunction, "f", 7, 9)},
+		},
+		{
+			name:   "class with methods",
+			source: "class C(Base):\n    x = 1\n\n    def m(self):\n        return 1\n\n    async def n(self):\n        pass\n\n\ndef g():\n    pass\n",
+			want: []string{
+				def(KindClass, "C", 1, 8),
+				def(KindMethod, "C.m", 4, 5),
+				def(KindMethod, "C.n", 7, 8),
+				def(KindFunction, "g", 11, 12),
+			},
+		},
+		{
+			name:   "one-line bodies",
+			source: "def f(): return 1\nclass E(Exception): pass\ndef g(x={'a': 1}): return x\n",
+			want: []string{
+				def(KindFunction, "f", 1, 1),
+				def(KindClass, "E", 2, 2),
+				def(KindFunction, "g", 3, 3),
+			},
+		},
+		{
+			name:   "docstring hides definitions",
+			source: "def f():\n    \"\"\"Usage:\n\ndef not_this():\n    \"\"\"\n    return 1\n",
+			want:   []string{def(KindFunction, "f", 1, 6)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDefinitions(t, PythonParser{}, tt.source, tt.want)
+		})
+	}
+}
+
+func TestPythonSignature(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"def f(a, b): return a\n", "def f(a, b)"},
+		{"def f(a,\n      b: int) -> int:\n    pass\n", "def f(a, b: int) -> int"},
+		{"def f(x={'k': 1}, s=':'): # note: x\n    pass\n", "def f(x={'k': 1}, s=':')"},
+		{"class C(Base): pass\n", "class C(Base)"},
+	}
+	for _, tt := range tests {
+		defs, err := PythonParser{}.Parse(tt.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(defs) != 1 || defs[0].Signature != tt.want {
+			t.Errorf("Parse(%q) = %+v, want signature %q", tt.source, defs, tt.want)
+		}
+	}
+}
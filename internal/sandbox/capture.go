@@ -0,0 +1,73 @@
+package sandbox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// capture is an io.Writer keeping the first and last max/2 bytes written to
+// it, so that a command's opening lines and its final errors both survive
+// however much it prints
+type capture struct {
+	max     int
+	head    []byte
+	tail    []byte // Ring buffer once full
+	next    int    // Next write position in tail
+	dropped int64
+}
+
+func newCapture(max int) *capture {
+	return &capture{max: max}
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	n := len(p)
+	if c.max <= 0 {
+		c.head = append(c.head, p...)
+		return n, nil
+	}
+
+	if room := c.max/2 - len(c.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		c.head = append(c.head, p[:room]...)
+		p = p[room:]
+	}
+
+	size := c.max - c.max/2
+	for len(p) > 0 {
+		if len(c.tail) < size {
+			room := size - len(c.tail)
+			if room > len(p) {
+				room = len(p)
+			}
+			c.tail = append(c.tail, p[:room]...)
+			p = p[room:]
+			continue
+		}
+		// Overwrite the oldest bytes
+		copied := copy(c.tail[c.next:], p)
+		c.dropped += int64(copied)
+		c.next = (c.next + copied) % size
+		p = p[copied:]
+	}
+	return n, nil
+}
+
+// Truncated reports whether any output was dropped
+func (c *capture) Truncated() bool {
+	return c.dropped > 0
+}
+
+// String returns the kept output, marking where bytes were dropped
+func (c *capture) String() string {
+	var b strings.Builder
+	b.Write(c.head)
+	if c.dropped > 0 {
+		fmt.Fprintf(&b, "\n[... %d bytes omitted ...]\n", c.dropped)
+	}
+	b.Write(c.tail[c.next:])
+	b.Write(c.tail[:c.next])
+	return strings.ToValidUTF8(b.String(), "")
+}
@@ -0,0 +1,141 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// waitDelay is how long a killed command's output pipes may stay open, held
+// by stray background processes, before Run stops waiting for them
+const waitDelay = 2 * time.Second
+
+// Limits are resource limits for isolated commands; zero means unlimited
+type Limits struct {
+	CPUSeconds int
+	MemoryMB   int // Virtual memory
+	FileSizeMB int // Largest file the command may write
+}
+
+// shellPrefix returns shell statements applying the limits, failing the
+// command if a limit cannot be set
+func (l Limits) shellPrefix() string {
+	var b strings.Builder
+	if l.CPUSeconds > 0 {
+		fmt.Fprintf(&b, "ulimit -t %d || exit 126\n", l.CPUSeconds)
+	}
+	if l.MemoryMB > 0 {
+		fmt.Fprintf(&b, "ulimit -v %d || exit 126\n", l.MemoryMB*1024)
+	}
+	if l.FileSizeMB > 0 {
+		// POSIX shells count file size in 512-byte blocks
+		fmt.Fprintf(&b, "ulimit -f %d || exit 126\n", l.FileSizeMB*2048)
+	}
+	return b.String()
+}
+
+// Result is the outcome of a command that ran
+type Result struct {
+	Stdout    string
+	Stderr    string
+	ExitCode  int  // -1 if the command was killed
+	Truncated bool // Output beyond MaxOutputBytes was dropped from the middle
+	TimedOut  bool
+	Timeout   time.Duration // Limit the command ran under, if any
+	Cancelled bool
+	Isolated  bool // The command had no network access
+	Duration  time.Duration
+}
+
+// Command creates the process for a shell command line running in dir. The
+// command gets its own process group, which is killed as a whole when ctx
+// ends. With isolation enabled it also gets the resource limits and, on
+// Linux where unprivileged user namespaces are available, an empty network
+// namespace. isolated reports whether the network could be cut off.
+func (p *Policy) Command(ctx context.Context, command, dir string) (cmd *exec.Cmd, isolated bool) {
+	if p.Isolate {
+		command = p.Limits.shellPrefix() + command
+	}
+	cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.WaitDelay = waitDelay
+	setProcessGroup(cmd)
+	if p.Isolate {
+		isolated = isolate(cmd)
+	}
+	return cmd, isolated
+}
+
+// Run runs a command line in dir, which must already have passed Check,
+// stopping it after timeout if that is positive or when ctx is cancelled.
+// Each output stream keeps at most MaxOutputBytes, taken from its start and
+// end; stdout and stderr, if not nil, also receive the full output as it is
+// written. A command that runs but fails is reported in the result, not as
+// an error.
+func (p *Policy) Run(ctx context.Context, command, dir string, timeout time.Duration, stdout, stderr io.Writer) (*Result, error) {
+	runCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd, isolated := p.Command(runCtx, command, dir)
+	stdoutCapture := newCapture(p.MaxOutputBytes)
+	stderrCapture := newCapture(p.MaxOutputBytes)
+	cmd.Stdout = tee(stdoutCapture, stdout)
+	cmd.Stderr = tee(stderrCapture, stderr)
+
+	start := time.Now()
+	err := cmd.Run()
+	result := &Result{
+		Stdout:    stdoutCapture.String(),
+		Stderr:    stderrCapture.String(),
+		Truncated: stdoutCapture.Truncated() || stderrCapture.Truncated(),
+		Timeout:   timeout,
+		Isolated:  isolated,
+		Duration:  time.Since(start),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil || errors.Is(err, exec.ErrWaitDelay):
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		return nil, fmt.Errorf("failed to run command: %v", err)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		result.Cancelled = true
+	case runCtx.Err() != nil:
+		result.TimedOut = true
+	}
+	return result, nil
+}
+
+func tee(capture *capture, w io.Writer) io.Writer {
+	if w == nil {
+		return capture
+	}
+	return io.MultiWriter(capture, w)
+}
+
+// Quote joins words into a shell command line, quoting where needed
+func Quote(words ...string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		if w != "" && strings.Trim(w, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-./=:,+@%") == "" {
+			quoted[i] = w
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(w, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package sandbox
+
+import "os/exec"
+
+// isolate is a no-op outside Linux; only resource limits apply
+func isolate(cmd *exec.Cmd) bool {
+	return false
+}
@@ -0,0 +1,476 @@
+// Package sandbox decides which shell commands tools may run and runs them
+// with the configured isolation
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azhany/codecli/internal/config"
+	"github.com/azhany/codecli/internal/workspace"
+)
+
+var (
+	// ErrDenied is returned for commands the policy does not permit
+	ErrDenied = errors.New("command not permitted")
+	// ErrInvalidTimeout is returned by NewPolicy for a malformed sandbox.timeout
+	ErrInvalidTimeout = errors.New("invalid sandbox.timeout")
+)
+
+// Approval is a user's answer to an approval prompt
+type Approval int
+
+const (
+	Reject        Approval = iota
+	ApproveOnce            // Run this time only
+	ApproveAlways          // Run this exact command without asking for the rest of the session
+)
+
+// Approver asks the user whether a command may run
+type Approver interface {
+	ApproveCommand(command, dir string) (Approval, error)
+}
+
+// Policy decides whether commands may run.
+//
+// A command line is split into its pipeline and list segments (|, ;, &&,
+// ...). It is refused if the whole line or any segment matches a deny
+// pattern, and runs without asking if every segment matches an allow
+// pattern, it uses no command substitution or redirection, and its
+// arguments stay in the workspace: no argument may name a path outside the
+// workspace root or be one of the flags that run other programs or write
+// files (see unsafeFlags). Anything else needs the approver's consent;
+// without an approver, or in read-only mode, it is refused.
+type Policy struct {
+	Root     string // Commands run in this directory or below it
+	ReadOnly bool
+	Isolate  bool // Run commands in an isolated process, see Command
+	Limits   Limits
+
+	Timeout        time.Duration // Default limit on a command's running time
+	MaxOutputBytes int           // Output kept per stream by Run; 0 keeps all
+
+	allow    []commandPattern
+	deny     []commandPattern
+	approver Approver
+
+	mu       sync.Mutex
+	approved map[string]bool // Commands approved for the session
+}
+
+// NewPolicy creates a policy from the sandbox settings, confined to the
+// workspace root
+func NewPolicy() (*Policy, error) {
+	cfg := config.Config.Sandbox
+	p := &Policy{
+		Root:     config.Config.Workspace.Root,
+		ReadOnly: cfg.ReadOnly,
+		Isolate:  cfg.Isolate,
+		Limits: Limits{
+			CPUSeconds: cfg.MaxCPUSeconds,
+			MemoryMB:   cfg.MaxMemoryMB,
+			FileSizeMB: cfg.MaxFileSizeMB,
+		},
+		MaxOutputBytes: cfg.MaxOutputBytes,
+		approved:       make(map[string]bool),
+	}
+
+	var err error
+	if p.allow, err = compilePatterns(cfg.Allow); err != nil {
+		return nil, fmt.Errorf("invalid sandbox.allow pattern: %v", err)
+	}
+	if p.deny, err = compilePatterns(cfg.Deny); err != nil {
+		return nil, fmt.Errorf("invalid sandbox.deny pattern: %v", err)
+	}
+	if cfg.Timeout != "" {
+		if p.Timeout, err = time.ParseDuration(cfg.Timeout); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTimeout, err)
+		}
+	}
+	return p, nil
+}
+
+// SetApprover sets who is asked about commands outside the allow list
+func (p *Policy) SetApprover(approver Approver) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.approver = approver
+}
+
+// WorkDir resolves a command's working directory, which must lie inside the
+// workspace root. An empty dir is the root itself.
+func (p *Policy) WorkDir(dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	return workspace.Resolve(p.Root, dir)
+}
+
+// Check decides whether command may run in dir, asking the approver if
+// needed. It returns an error wrapping ErrDenied if not.
+func (p *Policy) Check(command, dir string) error {
+	segments, complex := splitCommand(command)
+	if len(segments) == 0 {
+		return fmt.Errorf("%w: empty command", ErrDenied)
+	}
+
+	for _, s := range append([]string{strings.TrimSpace(command)}, segments...) {
+		if pattern, ok := match(p.deny, s); ok {
+			return fmt.Errorf("%w: %q matches deny pattern %q", ErrDenied, s, pattern.source)
+		}
+	}
+
+	if !complex && allMatch(p.allow, segments) && p.safeArguments(segments, dir) {
+		return nil
+	}
+
+	p.mu.Lock()
+	approved := p.approved[command]
+	approver := p.approver
+	p.mu.Unlock()
+	if approved {
+		return nil
+	}
+
+	switch {
+	case p.ReadOnly:
+		return fmt.Errorf("%w: read-only mode only runs commands matching sandbox.allow", ErrDenied)
+	case approver == nil:
+		return fmt.Errorf("%w: not in sandbox.allow and no one is available to approve it", ErrDenied)
+	}
+
+	approval, err := approver.ApproveCommand(command, dir)
+	if err != nil {
+		return fmt.Errorf("%w: approval failed: %v", ErrDenied, err)
+	}
+	switch approval {
+	case ApproveOnce:
+		return nil
+	case ApproveAlways:
+		p.mu.Lock()
+		p.approved[command] = true
+		p.mu.Unlock()
+		return nil
+	default:
+		return fmt.Errorf("%w: rejected by the user", ErrDenied)
+	}
+}
+
+// commandPattern is a compiled allow or deny pattern
+type commandPattern struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// compilePatterns compiles command patterns, where "*" matches any text and
+// "?" any single character, anchored at both ends
+func compilePatterns(patterns []string) ([]commandPattern, error) {
+	compiled := make([]commandPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		expr := regexp.QuoteMeta(strings.TrimSpace(pattern))
+		expr = strings.ReplaceAll(expr, `\*`, `.*`)
+		expr = strings.ReplaceAll(expr, `\?`, `.`)
+		re, err := regexp.Compile(`^` + expr + `$`)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pattern, err)
+		}
+		compiled = append(compiled, commandPattern{source: pattern, re: re})
+	}
+	return compiled, nil
+}
+
+func match(patterns []commandPattern, s string) (commandPattern, bool) {
+	for _, p := range patterns {
+		if p.re.MatchString(s) {
+			return p, true
+		}
+	}
+	return commandPattern{}, false
+}
+
+func allMatch(patterns []commandPattern, segments []string) bool {
+	for _, s := range segments {
+		if _, ok := match(patterns, s); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// unsafeFlags are, per command, the flags that run another program or write
+// to a file, such as go test -exec or git diff --output. A command using
+// one is never run without approval, whatever sandbox.allow says. Commands
+// listed without flags only read; commands not listed use otherUnsafeFlags.
+var unsafeFlags = map[string][]string{
+	"cat":  nil,
+	"head": nil,
+	"tail": nil,
+	"wc":   nil,
+	"ls":   nil,
+	"pwd":  nil,
+	"grep": nil,
+	"go":   {"-exec", "-toolexec", "-vettool", "-o"},
+	"git": {"-c", "--config-env", "--exec-path", "--output", "--ext-diff", "--upload-pack",
+		"--receive-pack", "--open-files-in-pager", "-O", "--exec"},
+	"find": {"-exec", "-execdir", "-ok", "-okdir", "-delete", "-fprint", "-fprint0", "-fprintf", "-fls"},
+	"rg":   {"--pre"},
+	"sort": {"-o", "--output"},
+}
+
+var otherUnsafeFlags = []string{
+	"-exec", "-execdir", "-toolexec", "-vettool", "-o", "--output",
+	"-c", "--exec", "--ext-diff", "--upload-pack", "--receive-pack",
+	"--open-files-in-pager", "-O",
+}
+
+// safeArguments reports whether the arguments of every segment stay in the
+// workspace: no unsafe flag, and every argument that may be a path resolves
+// inside the root when taken relative to dir. That includes the value of
+// "--flag=value" and a value attached to a short option, as in "-C/etc".
+func (p *Policy) safeArguments(segments []string, dir string) bool {
+	for _, segment := range segments {
+		words := splitWords(segment)
+		if len(words) == 0 {
+			continue
+		}
+		flags, ok := unsafeFlags[filepath.Base(words[0])]
+		if !ok {
+			flags = otherUnsafeFlags
+		}
+		for _, word := range words[1:] {
+			switch {
+			case strings.HasPrefix(word, "--"):
+				name, value, hasValue := strings.Cut(word, "=")
+				if contains(flags, name) || (hasValue && !p.insideRoot(value, dir)) {
+					return false
+				}
+			case strings.HasPrefix(word, "-") && len(word) > 1:
+				if unsafeShortFlag(word, flags) || !p.attachedValuesInside(word, dir) {
+					return false
+				}
+			default:
+				if !p.insideRoot(word, dir) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// unsafeShortFlag reports whether a single-dash word is one of flags, with
+// or without a value after "=". Single-letter flags also match with their
+// value attached, as in "-o/tmp/out".
+func unsafeShortFlag(word string, flags []string) bool {
+	name, _, _ := strings.Cut(word, "=")
+	for _, flag := range flags {
+		if name == flag || (len(flag) == 2 && flag[1] != '-' && strings.HasPrefix(word, flag)) {
+			return true
+		}
+	}
+	return false
+}
+
+// attachedValuesInside checks what may be a value attached to a short
+// option: the value of "-flag=value", and whatever follows each letter of
+// a bundle such as "-uo/tmp/out"
+func (p *Policy) attachedValuesInside(word, dir string) bool {
+	if name, value, ok := strings.Cut(word, "="); ok {
+		return p.insideRoot(value, dir) && p.attachedValuesInside(name, dir)
+	}
+	for i := 2; i < len(word); i++ {
+		if isLetter(word[i-1]) && !p.insideRoot(word[i:], dir) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// insideRoot reports whether a word, taken as a path relative to dir,
+// resolves inside the workspace root, as do all the files it matches as a
+// glob. Words the shell would expand in ways that cannot be checked here,
+// such as "~", "$HOME" or brace expansion, do not count as inside.
+func (p *Policy) insideRoot(word, dir string) bool {
+	if word == "" {
+		return true
+	}
+	if strings.HasPrefix(word, "~") || strings.ContainsAny(word, "${") {
+		return false
+	}
+	if !filepath.IsAbs(word) {
+		word = filepath.Join(dir, word)
+	}
+	if _, err := workspace.Resolve(p.Root, word); err != nil {
+		return false
+	}
+
+	if !strings.ContainsAny(word, "*?[") {
+		return true
+	}
+	// Some shells match "." and ".." with ".*"; filepath.Glob does not
+	for _, part := range strings.Split(word, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") && strings.ContainsAny(part, "*?[") {
+			return false
+		}
+	}
+	matches, err := filepath.Glob(word)
+	if err != nil {
+		return false
+	}
+	for _, match := range matches {
+		if _, err := workspace.Resolve(p.Root, match); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// splitWords splits a simple command into its words, removing quotes and
+// backslash escapes. Descriptor duplications such as 2>&1 are dropped.
+func splitWords(segment string) []string {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+		quote   byte
+	)
+	flush := func() {
+		if inWord && !dupRe.MatchString(current.String()) {
+			words = append(words, current.String())
+		}
+		current.Reset()
+		inWord = false
+	}
+
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' && i+1 < len(segment) && strings.IndexByte("\\\"$`", segment[i+1]) >= 0 {
+				i++
+				current.WriteByte(segment[i])
+			} else {
+				current.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(segment):
+			i++
+			current.WriteByte(segment[i])
+			inWord = true
+		case c == ' ' || c == '\t':
+			flush()
+		default:
+			current.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return words
+}
+
+// dupRe matches a descriptor duplication, such as 2>&1 or >&-
+var dupRe = regexp.MustCompile(`^[0-9]*[<>]&([0-9]+|-)$`)
+
+// splitCommand splits a shell command line into its simple commands at
+// unquoted |, ||, &, &&, ; and newlines. complex reports command
+// substitution, process substitution or redirection to a file, which an
+// allow pattern cannot vouch for.
+func splitCommand(command string) (segments []string, complex bool) {
+	var (
+		current strings.Builder
+		quote   byte
+	)
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			segments = append(segments, s)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(command):
+			current.WriteByte(c)
+			i++
+			c = command[i]
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '`' || (c == '$' && i+1 < len(command) && command[i+1] == '(') {
+				complex = true
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '`' || (c == '$' && i+1 < len(command) && command[i+1] == '('):
+			complex = true
+		case (c == '<' || c == '>') && i+1 < len(command) && command[i+1] == '(':
+			complex = true
+		case c == '>' || c == '<':
+			// "2>&1", ">&2" and "<&-" only duplicate or close descriptors;
+			// ">&file" redirects to a file
+			if i+1 >= len(command) || command[i+1] != '&' || !duplicatesDescriptor(command[i+2:]) {
+				complex = true
+			}
+		case c == ';' || c == '|' || c == '&' || c == '\n':
+			if c == '&' && i > 0 && (command[i-1] == '>' || command[i-1] == '<') {
+				break // Part of a descriptor duplication such as 2>&1
+			}
+			flush()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	flush()
+
+	return segments, complex
+}
+
+// duplicatesDescriptor reports whether the word after ">&" or "<&" is a
+// descriptor number or "-", rather than a file name
+func duplicatesDescriptor(rest string) bool {
+	rest = strings.TrimLeft(rest, " \t")
+	end := strings.IndexAny(rest, " \t\n;|&<>()")
+	if end < 0 {
+		end = len(rest)
+	}
+	word := rest[:end]
+	if word == "-" {
+		return true
+	}
+	if word == "" {
+		return false
+	}
+	for _, c := range word {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
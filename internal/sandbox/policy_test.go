@@ -0,0 +1,182 @@
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		segments []string
+		complex  bool
+	}{
+		{"ls -la", []string{"ls -la"}, false},
+		{"git status && go vet ./...", []string{"git status", "go vet ./..."}, false},
+		{"cat a | wc -l; pwd", []string{"cat a", "wc -l", "pwd"}, false},
+		{"echo 'a | b; c'", []string{"echo 'a | b; c'"}, false},
+		{`echo "a && b"`, []string{`echo "a && b"`}, false},
+		{`echo a\;b`, []string{`echo a\;b`}, false},
+		{"make 2>&1 | tail", []string{"make 2>&1", "tail"}, false},
+		{"ls >&2", []string{"ls >&2"}, false},
+		{"ls 2>&-", []string{"ls 2>&-"}, false},
+		{"sleep 1 &", []string{"sleep 1"}, false},
+		{"ls > out", []string{"ls > out"}, true},
+		{"ls >> out", []string{"ls >> out"}, true},
+		{"ls >&/tmp/out", []string{"ls >&/tmp/out"}, true},
+		{"ls >& /tmp/out", []string{"ls >& /tmp/out"}, true},
+		{"ls 2>&out", []string{"ls 2>&out"}, true},
+		{"cat < /etc/passwd", []string{"cat < /etc/passwd"}, true},
+		{"cat <(ls)", []string{"cat <(ls)"}, true},
+		{"echo $(whoami)", []string{"echo $(whoami)"}, true},
+		{"echo `whoami`", []string{"echo `whoami`"}, true},
+		{`echo "$(whoami)"`, []string{`echo "$(whoami)"`}, true},
+		{"echo '$(whoami)'", []string{"echo '$(whoami)'"}, false},
+		{"  ", nil, false},
+	}
+	for _, tt := range tests {
+		segments, complex := splitCommand(tt.command)
+		if !reflect.DeepEqual(segments, tt.segments) || complex != tt.complex {
+			t.Errorf("splitCommand(%q) = %q, %v; want %q, %v", tt.command, segments, complex, tt.segments, tt.complex)
+		}
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		segment string
+		words   []string
+	}{
+		{"ls -la", []string{"ls", "-la"}},
+		{`cat "a b" 'c d' e\ f`, []string{"cat", "a b", "c d", "e f"}},
+		{`echo "a\"b" ''`, []string{"echo", `a"b`, ""}},
+		{"make 2>&1", []string{"make"}},
+	}
+	for _, tt := range tests {
+		if words := splitWords(tt.segment); !reflect.DeepEqual(words, tt.words) {
+			t.Errorf("splitWords(%q) = %q, want %q", tt.segment, words, tt.words)
+		}
+	}
+}
+
+// approver answers every approval prompt the same way
+type approver Approval
+
+func (a approver) ApproveCommand(command, dir string) (Approval, error) {
+	return Approval(a), nil
+}
+
+func TestCheck(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "x"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	allow, err := compilePatterns([]string{"ls", "ls *", "cat *", "head *", "tail *", "wc *", "git *", "sort *", "go vet*", "go test*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deny, err := compilePatterns([]string{"sudo *", "rm -rf /*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &Policy{Root: root, ReadOnly: true, allow: allow, deny: deny, approved: map[string]bool{}}
+
+	tests := []struct {
+		command string
+		dir     string
+		allowed bool
+	}{
+		{"ls", root, true},
+		{"ls -la sub", root, true},
+		{"cat main.go | wc -l", root, true},
+		{"cat *.go", root, true},
+		{"cat ../x", filepath.Join(root, "sub"), true},
+		{"git diff --stat", root, true},
+		{"go vet ./...", root, true},
+		{"head -c 100 main.go", root, true},
+		{"tail -c100 main.go", root, true},
+		{"ls -la -n5", root, true},
+
+		// Paths outside the workspace
+		{"cat /etc/passwd", root, false},
+		{"cat ~/.ssh/id_rsa", root, false},
+		{"cat $HOME/.ssh/id_rsa", root, false},
+		{"cat ../x", root, false},
+		{"cat escape/x", root, false},
+		{"cat escap*/x", root, false},
+		{"cat .*/x", root, false},
+		{"ls --directory=/etc", root, false},
+		{"cat {/etc/passwd,README.md}", root, false},
+		{"cat sub/{a,b}", root, false},
+		{"git -C/etc log", root, false},
+		{"ls -d/etc", root, false},
+		{"ls -ld/etc", root, false},
+		{"ls -x=/etc", root, false},
+
+		// Flags that run programs or write files
+		{`go test -exec "sh -c 'touch /tmp/pwned'" ./...`, root, false},
+		{"go test -toolexec=evil ./...", root, false},
+		{"go vet -vettool=evil ./...", root, false},
+		{"git diff --output=out", root, false},
+		{"git -c core.pager=evil log", root, false},
+		{"git log -Oorder", root, false},
+		{"go test -o/tmp/x ./...", root, false},
+		{"sort -o out main.go", root, false},
+
+		// Redirections and substitutions
+		{"ls >&/tmp/pwned", root, false},
+		{"ls > out", root, false},
+		{"ls $(whoami)", root, false},
+
+		// Not allow-listed, or denied
+		{"rm -rf sub", root, false},
+		{"sudo ls", root, false},
+		{"", root, false},
+	}
+	for _, tt := range tests {
+		err := policy.Check(tt.command, tt.dir)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("Check(%q) = %v, want allowed %v", tt.command, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrDenied) {
+			t.Errorf("Check(%q) = %v, want ErrDenied", tt.command, err)
+		}
+	}
+}
+
+func TestCheckApproval(t *testing.T) {
+	root := t.TempDir()
+	policy := &Policy{Root: root, approved: map[string]bool{}}
+
+	if err := policy.Check("make", root); !errors.Is(err, ErrDenied) {
+		t.Fatalf("without an approver: %v, want ErrDenied", err)
+	}
+
+	policy.SetApprover(approver(Reject))
+	if err := policy.Check("make", root); !errors.Is(err, ErrDenied) {
+		t.Errorf("rejected: %v, want ErrDenied", err)
+	}
+
+	policy.SetApprover(approver(ApproveAlways))
+	if err := policy.Check("make", root); err != nil {
+		t.Fatalf("approved: %v", err)
+	}
+	policy.SetApprover(approver(Reject))
+	if err := policy.Check("make", root); err != nil {
+		t.Errorf("approved for the session: %v", err)
+	}
+	if err := policy.Check("make install", root); !errors.Is(err, ErrDenied) {
+		t.Errorf("other command: %v, want ErrDenied", err)
+	}
+}
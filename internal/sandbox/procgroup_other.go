@@ -0,0 +1,9 @@
+//go:build !unix
+
+package sandbox
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are unavailable; only the
+// shell itself is killed on cancellation
+func setProcessGroup(cmd *exec.Cmd) {}
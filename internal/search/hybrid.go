@@ -0,0 +1,98 @@
+package search
+
+import (
+	"sort"
+
+	"github.com/azhany/codecli/internal/config"
+	"github.com/azhany/codecli/internal/types"
+)
+
+// hybridCandidates is how many results are fetched from each ranking per
+// requested result, so that items ranked low by one side can still surface
+const hybridCandidates = 4
+
+// HybridEngine fuses a semantic and a lexical ranking of the same chunks
+// with weighted reciprocal rank fusion: each result scores
+// sum(weight / (k + rank)) over the rankings it appears in.
+type HybridEngine struct {
+	Semantic       Engine
+	Lexical        Engine
+	SemanticWeight float64
+	LexicalWeight  float64
+	K              int // Damping constant; larger values flatten rank differences
+}
+
+// NewHybridEngine creates a hybrid engine weighted from the search settings
+func NewHybridEngine(semantic, lexical Engine) *HybridEngine {
+	cfg := config.Config.Search
+	return &HybridEngine{
+		Semantic:       semantic,
+		Lexical:        lexical,
+		SemanticWeight: cfg.SemanticWeight,
+		LexicalWeight:  cfg.LexicalWeight,
+		K:              cfg.RRFK,
+	}
+}
+
+// Search returns up to limit results ordered by fused score. Each result
+// carries the component scores of the rankings it was found in.
+func (e *HybridEngine) Search(query string, limit int) ([]types.SearchResult, error) {
+	candidates := limit * hybridCandidates
+	if candidates < 50 {
+		candidates = 50
+	}
+
+	semantic, err := e.Semantic.Search(query, candidates)
+	if err != nil {
+		return nil, err
+	}
+	lexical, err := e.Lexical.Search(query, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	k := e.K
+	if k <= 0 {
+		k = 60
+	}
+
+	type location struct {
+		path string
+		line int
+	}
+	fused := make(map[location]*types.SearchResult)
+	var order []location
+	add := func(results []types.SearchResult, weight float64, lexical bool) {
+		for rank, r := range results {
+			loc := location{r.Path, r.Line}
+			merged, ok := fused[loc]
+			if !ok {
+				r := r
+				merged = &r
+				fused[loc] = merged
+				order = append(order, loc)
+			}
+			if lexical {
+				merged.LexicalScore = r.LexicalScore
+			} else {
+				merged.SemanticScore = r.SemanticScore
+				merged.Distance = r.Distance
+			}
+			merged.Score += weight / float64(k+rank+1)
+		}
+	}
+	add(semantic, e.SemanticWeight, false)
+	add(lexical, e.LexicalWeight, true)
+
+	results := make([]types.SearchResult, 0, len(order))
+	for _, loc := range order {
+		results = append(results, *fused[loc])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
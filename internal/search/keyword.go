@@ -0,0 +1,170 @@
+package search
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/azhany/codecli/internal/types"
+	"github.com/azhany/codecli/internal/workspace"
+)
+
+// keywordBatchSize is the number of files searched concurrently before the
+// result limit is checked again
+const keywordBatchSize = 64
+
+// KeywordEngine searches workspace files line by line for a literal string or
+// regular expression. It needs no index and no LLM.
+type KeywordEngine struct {
+	Root         string
+	Regex        bool // Treat the query as a regular expression
+	IgnoreCase   bool
+	ContextLines int      // Lines of context reported before and after each match
+	Extensions   []string // Only search files with these extensions; empty searches all
+}
+
+// NewKeywordEngine creates a keyword engine rooted at root
+func NewKeywordEngine(root string) *KeywordEngine {
+	return &KeywordEngine{Root: root}
+}
+
+// Search returns up to limit matching lines, ordered by path and line
+func (e *KeywordEngine) Search(query string, limit int) ([]types.SearchResult, error) {
+	re, err := e.compile(query)
+	if err != nil {
+		return nil, err
+	}
+
+	walker := workspace.NewWalker(e.Root)
+	walker.Extensions = e.Extensions
+	files, err := walker.Files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk workspace: %v", err)
+	}
+
+	workers := runtime.NumCPU()
+	if workers > keywordBatchSize {
+		workers = keywordBatchSize
+	}
+
+	// Files are searched in batches so results stay in walk order and the
+	// search stops once enough matches have been found
+	var results []types.SearchResult
+	for start := 0; start < len(files); start += keywordBatchSize {
+		end := start + keywordBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+		batch := files[start:end]
+
+		matches := make([][]types.SearchResult, len(batch))
+		errs := make([]error, len(batch))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					matches[i], errs[i] = e.searchFile(batch[i], re)
+				}
+			}()
+		}
+		for i := range batch {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		for i := range batch {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			results = append(results, matches[i]...)
+		}
+		if limit > 0 && len(results) >= limit {
+			return results[:limit], nil
+		}
+	}
+
+	return results, nil
+}
+
+func (e *KeywordEngine) compile(query string) (*regexp.Regexp, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	pattern := query
+	if !e.Regex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if e.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	return re, nil
+}
+
+// searchFile returns the matching lines of one file with their context
+func (e *KeywordEngine) searchFile(path string, re *regexp.Regexp) ([]types.SearchResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var (
+		results []types.SearchResult
+		before  []string // Ring of the last ContextLines lines
+		pending []int    // Results still collecting trailing context
+		lineNum int
+	)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// Feed trailing context to earlier matches
+		open := pending[:0]
+		for _, idx := range pending {
+			results[idx].After = append(results[idx].After, line)
+			if len(results[idx].After) < e.ContextLines {
+				open = append(open, idx)
+			}
+		}
+		pending = open
+
+		if re.MatchString(line) {
+			results = append(results, types.SearchResult{
+				Path:    path,
+				Line:    lineNum,
+				Content: line,
+				Before:  append([]string(nil), before...),
+			})
+			if e.ContextLines > 0 {
+				pending = append(pending, len(results)-1)
+			}
+		}
+
+		if e.ContextLines > 0 {
+			if len(before) == e.ContextLines {
+				before = before[1:]
+			}
+			before = append(before, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return results, nil
+}
@@ -1,6 +1,9 @@
 package search
 
 import (
+	"fmt"
+
+	"github.com/azhany/codecli/internal/config"
 	"github.com/azhany/codecli/internal/types"
 )
 
@@ -9,28 +12,40 @@ type Engine interface {
 	Search(query string, limit int) ([]types.SearchResult, error)
 }
 
-// DefaultEngine is the default implementation of the search engine
-type DefaultEngine struct {
-	// Add fields for vector store, etc.
-}
+// Type selects the search engine
+type Type string
+
+const (
+	TypeKeyword  Type = "keyword"
+	TypeSemantic Type = "semantic"
+	TypeBoth     Type = "both"
+)
 
-// NewDefaultEngine creates a new default search engine
-func NewDefaultEngine() *DefaultEngine {
-	return &DefaultEngine{}
+// ParseType validates a search type name
+func ParseType(s string) (Type, error) {
+	switch t := Type(s); t {
+	case TypeKeyword, TypeSemantic, TypeBoth:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unknown search type %q (expected keyword, semantic or both)", s)
+	}
 }
 
-// Search performs a semantic search using the vector store
-func (e *DefaultEngine) Search(query string, limit int) ([]types.SearchResult, error) {
-	// TODO: Implement semantic search using vector store
-	return []types.SearchResult{}, nil
+// EngineFunc adapts a search function to the Engine interface
+type EngineFunc func(query string, limit int) ([]types.SearchResult, error)
+
+// Search calls f(query, limit)
+func (f EngineFunc) Search(query string, limit int) ([]types.SearchResult, error) {
+	return f(query, limit)
 }
 
-// SearchCodebase is a convenience function that uses the default engine
+// SearchCodebase is a convenience function that runs a keyword search over
+// the workspace root
 func SearchCodebase(query string, limit int) ([]types.SearchResult, error) {
 	if limit <= 0 {
 		limit = 10
 	}
 
-	engine := NewDefaultEngine()
+	engine := NewKeywordEngine(config.Config.Workspace.Root)
 	return engine.Search(query, limit)
 }
@@ -1,13 +1,31 @@
 package tools
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azhany/codecli/internal/sandbox"
+	"github.com/azhany/codecli/internal/types"
 )
 
-// Command handles shell command execution
+// Command handles shell command execution. Every command is checked against
+// the sandbox policy and runs inside the workspace root.
 type Command struct {
 	*Base
+
+	// Stdout and Stderr, if set, receive the output of commands as they run,
+	// in addition to the captured output in the result
+	Stdout io.Writer
+	Stderr io.Writer
+
+	once      sync.Once
+	policy    *sandbox.Policy
+	policyErr error
+	approver  sandbox.Approver
 }
 
 func NewCommand() *Command {
@@ -16,32 +34,144 @@ func NewCommand() *Command {
 	}
 }
 
+func (t *Command) Schema() *types.Schema {
+	return &types.Schema{
+		Type: "object",
+		Properties: map[string]*types.Schema{
+			"command": {Type: "string", Description: "Shell command line to run with sh -c"},
+			"workdir": {Type: "string", Description: "Directory to run the command in, inside the workspace (default: workspace root)"},
+			"timeout": {Type: "integer", Description: "Seconds before the command is killed (default: sandbox.timeout)"},
+		},
+		Required: []string{"command"},
+	}
+}
+
+// SetApprover sets who is asked about commands the sandbox policy does not
+// allow outright
+func (t *Command) SetApprover(approver sandbox.Approver) {
+	t.approver = approver
+	if t.policy != nil {
+		t.policy.SetApprover(approver)
+	}
+}
+
+// Policy returns the sandbox policy, created from the configuration on first
+// use
+func (t *Command) Policy() (*sandbox.Policy, error) {
+	t.once.Do(func() {
+		t.policy, t.policyErr = sandbox.NewPolicy()
+		if t.policy != nil && t.approver != nil {
+			t.policy.SetApprover(t.approver)
+		}
+	})
+	return t.policy, t.policyErr
+}
+
+// RunCommand runs a program with arguments and returns its standard output.
+// If it fails, the error includes what it printed.
 func (t *Command) RunCommand(cmd string, args ...string) (string, error) {
-	command := exec.Command(cmd, args...)
-	output, err := command.CombinedOutput()
+	line := sandbox.Quote(append([]string{cmd}, args...)...)
+	result, err := t.run(context.Background(), line, "", 0)
 	if err != nil {
-		return "", fmt.Errorf("command failed: %v", err)
+		return "", err
+	}
+	if err := commandError(result); err != nil {
+		output := strings.TrimRight(result.Stderr, "\n")
+		if output == "" {
+			output = strings.TrimRight(result.Stdout, "\n")
+		}
+		if output != "" {
+			return result.Stdout, fmt.Errorf("%v\n%s", err, output)
+		}
+		return result.Stdout, err
 	}
-	return string(output), nil
+	return result.Stdout, nil
 }
 
-func (t *Command) Execute(args map[string]interface{}) (interface{}, error) {
+func (t *Command) Execute(args map[string]interface{}) (*types.ToolResult, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the command, killing it when ctx ends. A command that
+// exits unsuccessfully returns its output along with the error.
+func (t *Command) ExecuteContext(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
 	cmd, ok := args["command"].(string)
 	if !ok {
 		return nil, fmt.Errorf("command argument is required")
 	}
-
 	workdir, _ := args["workdir"].(string)
-	if workdir == "" {
-		workdir = "."
+	seconds, _ := args["timeout"].(int)
+
+	result, err := t.run(ctx, cmd, workdir, time.Duration(seconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	toolResult := commandResult(result)
+	toolResult.Data = map[string]interface{}{
+		"command":   cmd,
+		"stdout":    result.Stdout,
+		"stderr":    result.Stderr,
+		"exit_code": result.ExitCode,
+		"timed_out": result.TimedOut,
 	}
+	toolResult.SetMetadata("network_isolated", result.Isolated)
+	toolResult.SetMetadata("duration_ms", result.Duration.Milliseconds())
+	return toolResult, commandError(result)
+}
 
-	command := exec.Command("sh", "-c", cmd)
-	command.Dir = workdir
-	output, err := command.CombinedOutput()
+// run checks a command line against the policy and runs it in workdir. A
+// zero timeout means the configured default.
+func (t *Command) run(ctx context.Context, cmd, workdir string, timeout time.Duration) (*sandbox.Result, error) {
+	policy, err := t.Policy()
 	if err != nil {
-		return nil, fmt.Errorf("command failed: %v", err)
+		return nil, err
+	}
+	dir, err := policy.WorkDir(workdir)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Check(cmd, dir); err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		timeout = policy.Timeout
+	}
+	return policy.Run(ctx, cmd, dir, timeout, t.Stdout, t.Stderr)
+}
+
+// commandResult shows a command's output, labelling standard error when the
+// command printed to both streams
+func commandResult(result *sandbox.Result) *types.ToolResult {
+	stdout := strings.TrimRight(result.Stdout, "\n")
+	stderr := strings.TrimRight(result.Stderr, "\n")
+
+	var blocks []types.ContentBlock
+	switch {
+	case stdout != "" && stderr != "":
+		blocks = []types.ContentBlock{
+			{Type: types.BlockText, Text: stdout},
+			{Type: types.BlockText, Text: "stderr:\n" + stderr},
+		}
+	case stdout != "" || stderr != "":
+		blocks = []types.ContentBlock{{Type: types.BlockText, Text: stdout + stderr}}
+	case result.ExitCode == 0:
+		blocks = []types.ContentBlock{{Type: types.BlockText, Text: "(no output)"}}
 	}
 
-	return string(output), nil
+	return &types.ToolResult{Content: blocks, Truncated: result.Truncated}
+}
+
+// commandError describes why a command did not succeed, or returns nil
+func commandError(result *sandbox.Result) error {
+	switch {
+	case result.Cancelled:
+		return context.Canceled
+	case result.TimedOut:
+		return fmt.Errorf("command killed after timing out at %s", result.Timeout)
+	case result.ExitCode != 0:
+		return fmt.Errorf("command exited with status %d", result.ExitCode)
+	}
+	return nil
 }
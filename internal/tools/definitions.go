@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/azhany/codecli/internal/parser"
+	"github.com/azhany/codecli/internal/types"
+	"github.com/azhany/codecli/internal/workspace"
+)
+
+// FileDefinitions lists the definitions found in one file
+type FileDefinitions struct {
+	Path        string              `json:"path"`
+	Language    string              `json:"language"`
+	Definitions []parser.Definition `json:"definitions"`
+}
+
+// Definitions lists the functions, types, classes and constants defined in
+// a file or the files below a directory; see the parser package
+type Definitions struct {
+	*Base
+
+	once           sync.Once
+	confinement    *workspace.Confinement
+	confinementErr error
+}
+
+func NewDefinitions() *Definitions {
+	return &Definitions{
+		Base: NewBase("definitions", "Lists the functions, methods, types, classes and constants defined in a file or directory, with their line ranges"),
+	}
+}
+
+func (t *Definitions) Schema() *types.Schema {
+	return &types.Schema{
+		Type: "object",
+		Properties: map[string]*types.Schema{
+			"path":     {Type: "string", Description: "File or directory to outline, relative to the workspace root (default: the root)"},
+			"pattern":  {Type: "string", Description: "Glob matched against file names when path is a directory, e.g. *.go"},
+			"language": {Type: "string", Description: "Parse files as this language instead of guessing from their extension", Enum: parser.Languages()},
+		},
+	}
+}
+
+func (t *Definitions) Execute(args map[string]interface{}) (*types.ToolResult, error) {
+	t.once.Do(func() {
+		t.confinement, t.confinementErr = workspace.NewConfinement()
+	})
+	if t.confinementErr != nil {
+		return nil, t.confinementErr
+	}
+
+	path, _ := args["path"].(string)
+	pattern, _ := args["pattern"].(string)
+	language, _ := args["language"].(string)
+
+	resolved, err := t.confinement.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileDefinitions
+	if info.IsDir() {
+		files, err = t.outlineDir(resolved, pattern, language)
+	} else {
+		var file FileDefinitions
+		file, err = t.outline(resolved, language)
+		files = []FileDefinitions{file}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return types.TextResult(formatDefinitions(files), files), nil
+}
+
+// outline parses a single file
+func (t *Definitions) outline(path, language string) (FileDefinitions, error) {
+	if language == "" {
+		language = parser.LanguageOf(path)
+	}
+	defs, err := parser.ParseFile(path, language)
+	if err != nil {
+		return FileDefinitions{}, err
+	}
+	return FileDefinitions{Path: t.confinement.Rel(path), Language: language, Definitions: defs}, nil
+}
+
+// outlineDir parses the supported files below root, skipping files without
+// definitions
+func (t *Definitions) outlineDir(root, pattern, language string) ([]FileDefinitions, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+
+	var files []FileDefinitions
+	err := workspace.NewWalker(root).Walk(func(path string, info os.FileInfo) error {
+		// Skip symlinks leading out of the workspace
+		if _, err := t.confinement.Resolve(path); err != nil {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); !matched {
+			return nil
+		}
+		if language == "" && parser.LanguageOf(path) == "" {
+			return nil
+		}
+
+		file, err := t.outline(path, language)
+		if err != nil || len(file.Definitions) == 0 {
+			return nil // Unreadable files are skipped like unsupported ones
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %v", err)
+	}
+	return files, nil
+}
+
+// formatDefinitions lists definitions under their file, one per line with
+// its line range, kind, qualified name and signature
+func formatDefinitions(files []FileDefinitions) string {
+	var b strings.Builder
+	for i, file := range files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(file.Path + "\n")
+		if len(file.Definitions) == 0 {
+			b.WriteString("  (no definitions found)\n")
+		}
+		for _, def := range file.Definitions {
+			fmt.Fprintf(&b, "  %d-%d %s %s", def.StartLine, def.EndLine, def.Kind, def.QualifiedName())
+			if def.Signature != "" {
+				fmt.Fprintf(&b, ": %s", def.Signature)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(files) == 0 {
+		return "No definitions found"
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
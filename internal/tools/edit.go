@@ -0,0 +1,250 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/azhany/codecli/internal/config"
+	"github.com/azhany/codecli/internal/diff"
+	"github.com/azhany/codecli/internal/types"
+)
+
+// errNoChange is returned by edits that leave the file as it was
+var errNoChange = errors.New("no changes")
+
+// Decision is a user's answer to a proposed file change
+type Decision int
+
+const (
+	RejectChange Decision = iota
+	AcceptChange
+	AcceptAllChanges // Accept this and every later change without asking
+)
+
+// Reviewer is shown each file change before it is written, as a unified
+// diff together with the proposed content. It may return different content,
+// such as the user's edit of the proposal, which is written instead.
+type Reviewer interface {
+	ReviewChange(path, diff, content string) (Decision, string, error)
+}
+
+// SetReviewer sets who confirms file changes; nil writes them directly
+func (t *File) SetReviewer(reviewer Reviewer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reviewer = reviewer
+	t.acceptAll = false
+}
+
+// review asks the reviewer, if any, to confirm a change, shown as edits
+// from diff.Compute, returning the content to write and whether the user
+// altered it
+func (t *File) review(path, after string, edits []diff.Edit) (string, bool, error) {
+	t.mu.Lock()
+	reviewer, acceptAll := t.reviewer, t.acceptAll
+	t.mu.Unlock()
+	if reviewer == nil || acceptAll {
+		return after, false, nil
+	}
+
+	unified := diff.Format("a/"+path, "b/"+path, edits, diff.DefaultContext)
+	decision, content, err := reviewer.ReviewChange(path, unified, after)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to review the change to %s: %v", path, err)
+	}
+	switch decision {
+	case AcceptAllChanges:
+		t.mu.Lock()
+		t.acceptAll = true
+		t.mu.Unlock()
+	case AcceptChange:
+	default:
+		return "", false, fmt.Errorf("the user rejected the change to %s", path)
+	}
+	return content, content != after, nil
+}
+
+// edit changes the file at path: change receives its current content and
+// returns the new content. Once the reviewer, if any, accepts the change, the
+// new content replaces the file atomically after the old content is recorded
+// in the current checkpoint. A missing file counts as empty when create is
+// set. The result shows the diff.
+func (t *File) edit(path string, create bool, change func(content string) (string, error)) (*types.ToolResult, error) {
+	if config.Config.Sandbox.ReadOnly {
+		return nil, fmt.Errorf("cannot write %s: sandbox is in read-only mode", path)
+	}
+	confinement, err := t.Confinement()
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := confinement.ResolveWrite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil && !(create && os.IsNotExist(err)) {
+		return nil, err
+	}
+	before := string(data)
+
+	after, err := change(before)
+	if errors.Is(err, errNoChange) || (err == nil && after == before) {
+		return types.TextResult(fmt.Sprintf("No changes to %s", path),
+			map[string]interface{}{"path": path, "diff": ""}), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	edits := diff.Compute(before, after)
+	after, edited, err := t.review(path, after, edits)
+	if err != nil {
+		return nil, err
+	}
+	if edited {
+		edits = diff.Compute(before, after)
+	}
+	if after == before {
+		return types.TextResult(fmt.Sprintf("No changes to %s", path),
+			map[string]interface{}{"path": path, "diff": ""}), nil
+	}
+
+	if err := t.checkpoints.Snapshot(resolved); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(resolved, []byte(after)); err != nil {
+		return nil, err
+	}
+	result := editResult(path, after, edits)
+	if edited {
+		result.Content[0].Text += "; the user edited the proposed change, so the diff shows what was actually written"
+	}
+	return result, nil
+}
+
+// editResult reports a change to a file, made by edits from diff.Compute,
+// as a unified diff
+func editResult(path, after string, edits []diff.Edit) *types.ToolResult {
+	unified := diff.Format("a/"+path, "b/"+path, edits, diff.DefaultContext)
+	added, removed := diff.Stats(edits)
+	return &types.ToolResult{
+		Content: []types.ContentBlock{
+			{Type: types.BlockText, Text: fmt.Sprintf("Updated %s (+%d -%d)", path, added, removed)},
+			{Type: types.BlockCode, Text: unified, Language: "diff"},
+		},
+		Data: map[string]interface{}{
+			"path":    path,
+			"size":    len(after),
+			"diff":    unified,
+			"added":   added,
+			"removed": removed,
+		},
+	}
+}
+
+// writeFileAtomic replaces a file by renaming a temporary file over it, so
+// that readers never see it half written. An existing file keeps its mode.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// replaceText replaces old with new, which must occur exactly once unless
+// all is set
+func replaceText(content, old, new string, all bool) (string, error) {
+	if old == "" {
+		return "", fmt.Errorf("old text must not be empty")
+	}
+
+	count := strings.Count(content, old)
+	switch {
+	case count == 0:
+		return "", fmt.Errorf("old text not found; it must match the file exactly, including whitespace and indentation")
+	case count > 1 && !all:
+		var lines []string
+		for offset := 0; ; {
+			i := strings.Index(content[offset:], old)
+			if i < 0 {
+				break
+			}
+			lines = append(lines, fmt.Sprint(strings.Count(content[:offset+i], "\n")+1))
+			offset += i + len(old)
+		}
+		return "", fmt.Errorf("old text occurs %d times (at lines %s); include more surrounding lines to make it unique, or set replace_all",
+			count, strings.Join(lines, ", "))
+	}
+
+	if old == new {
+		return "", errNoChange
+	}
+	if all {
+		return strings.ReplaceAll(content, old, new), nil
+	}
+	return strings.Replace(content, old, new, 1), nil
+}
+
+// replaceLines replaces lines start to end, counted from 1 and inclusive,
+// with text
+func replaceLines(content string, start, end int, text string) (string, error) {
+	lines := diff.Lines(content)
+	switch {
+	case start < 1 || end < start:
+		return "", fmt.Errorf("invalid line range %d-%d", start, end)
+	case end > len(lines):
+		return "", fmt.Errorf("line range %d-%d is past the end of the file, which has %d lines", start, end, len(lines))
+	}
+
+	// The last line keeps its missing newline
+	if end < len(lines) || strings.HasSuffix(lines[end-1], "\n") {
+		text = terminate(text)
+	}
+	return strings.Join(lines[:start-1], "") + text + strings.Join(lines[end:], ""), nil
+}
+
+// insertLines inserts text before line, counted from 1; one past the last
+// line appends it
+func insertLines(content string, line int, text string) (string, error) {
+	lines := diff.Lines(content)
+	if line < 1 || line > len(lines)+1 {
+		return "", fmt.Errorf("line %d is outside the file, which has %d lines", line, len(lines))
+	}
+
+	before := strings.Join(lines[:line-1], "")
+	if before != "" && !strings.HasSuffix(before, "\n") {
+		before += "\n"
+	}
+	return before + terminate(text) + strings.Join(lines[line-1:], ""), nil
+}
+
+// terminate ends non-empty text with a newline
+func terminate(text string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return text + "\n"
+	}
+	return text
+}
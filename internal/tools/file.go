@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	"github.com/azhany/codecli/internal/checkpoint"
+	"github.com/azhany/codecli/internal/diff"
 	"github.com/azhany/codecli/internal/search"
 	"github.com/azhany/codecli/internal/types"
+	"github.com/azhany/codecli/internal/workspace"
 )
 
 type FileOperation string
@@ -16,61 +21,155 @@ const (
 	FileWrite  FileOperation = "write"
 	FileList   FileOperation = "list"
 	FileSearch FileOperation = "search"
+
+	// Edits; each reports the change as a unified diff
+	FileAppend       FileOperation = "append"
+	FileReplace      FileOperation = "replace"
+	FileReplaceLines FileOperation = "replace_lines"
+	FileInsert       FileOperation = "insert"
+	FilePatch        FileOperation = "patch"
 )
 
-// File handles file operations
+// File handles file operations. Paths are taken relative to the workspace
+// root and confined to it; see workspace.Confinement.
 type File struct {
 	*Base
+
+	once           sync.Once
+	confinement    *workspace.Confinement
+	confinementErr error
+	checkpoints    *checkpoint.Store // Records files before they change
+
+	mu        sync.Mutex
+	reviewer  Reviewer
+	acceptAll bool // The user accepted all changes for the session
 }
 
 func NewFile() *File {
 	return &File{
-		Base: NewBase("file", "Handles file operations (read/write/list/search)"),
+		Base: NewBase("file", "Handles file operations (read/write/edit/list/search)"),
 	}
 }
 
+func (t *File) Schema() *types.Schema {
+	return &types.Schema{
+		Type: "object",
+		Properties: map[string]*types.Schema{
+			"operation": {
+				Type: "string",
+				Description: "read a file, write a whole file, append to it, edit it (replace: swap old text for new; " +
+					"replace_lines: replace start_line to end_line with content; insert: insert content before line; " +
+					"patch: apply a unified diff), list files under a directory, or search the workspace by keyword",
+				Enum: []string{
+					string(FileRead), string(FileWrite), string(FileAppend), string(FileReplace),
+					string(FileReplaceLines), string(FileInsert), string(FilePatch), string(FileList), string(FileSearch),
+				},
+			},
+			"path":        {Type: "string", Description: "File to read or change, or directory to list, relative to the workspace root (default: the root)"},
+			"content":     {Type: "string", Description: "Content to write, append or insert, or the replacement lines (write, append, insert, replace_lines)"},
+			"old":         {Type: "string", Description: "Exact text to replace, including indentation; must occur once unless replace_all is set (replace)"},
+			"new":         {Type: "string", Description: "Replacement text (replace)"},
+			"replace_all": {Type: "boolean", Description: "Replace every occurrence of old (replace)"},
+			"start_line":  {Type: "integer", Description: "First line to replace, counting from 1 (replace_lines)"},
+			"end_line":    {Type: "integer", Description: "Last line to replace, inclusive (replace_lines)"},
+			"line":        {Type: "integer", Description: "Line to insert before, counting from 1; one past the last line appends (insert)"},
+			"diff":        {Type: "string", Description: "Unified diff of the file to apply; fails without changes if any hunk does not match (patch)"},
+			"pattern":     {Type: "string", Description: "Glob matched against file names, e.g. *_test.go (list)"},
+			"query":       {Type: "string", Description: "Text to search for (search)"},
+		},
+		Required: []string{"operation"},
+	}
+}
+
+// Confinement returns the path confinement, created from the configuration
+// on first use
+func (t *File) Confinement() (*workspace.Confinement, error) {
+	t.once.Do(func() {
+		t.confinement, t.confinementErr = workspace.NewConfinement()
+		if t.confinementErr == nil {
+			t.checkpoints = checkpoint.NewStore(t.confinement.Root())
+		}
+	})
+	return t.confinement, t.confinementErr
+}
+
+// Checkpoints returns the store recording the files the tool changes
+func (t *File) Checkpoints() (*checkpoint.Store, error) {
+	if _, err := t.Confinement(); err != nil {
+		return nil, err
+	}
+	return t.checkpoints, nil
+}
+
 func (t *File) HandleFile(operation string, path string, data []byte) ([]byte, error) {
+	confinement, err := t.Confinement()
+	if err != nil {
+		return nil, err
+	}
+
 	switch FileOperation(operation) {
 	case FileRead:
-		return os.ReadFile(path)
+		resolved, err := confinement.Resolve(path)
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(resolved)
 	case FileWrite:
-		return nil, os.WriteFile(path, data, 0644)
+		_, err := t.edit(path, true, func(string) (string, error) { return string(data), nil })
+		return nil, err
+	case FileAppend:
+		_, err := t.edit(path, true, func(content string) (string, error) { return content + string(data), nil })
+		return nil, err
 	case FileList:
 		files, err := t.listFiles(path, string(data))
 		if err != nil {
 			return nil, err
 		}
-		result := []byte(fmt.Sprintf("%v", files))
-		return result, nil
+		return []byte(strings.Join(files, "\n")), nil
 	case FileSearch:
 		results, err := t.searchFiles(string(data), 10)
 		if err != nil {
 			return nil, err
 		}
-		result := []byte(fmt.Sprintf("%v", results))
-		return result, nil
+		return []byte(formatSearchResults(results)), nil
 	default:
 		return nil, fmt.Errorf("unknown operation: %s", operation)
 	}
 }
 
+// listFiles lists the files below root matching pattern, relative to the
+// workspace root
 func (t *File) listFiles(root string, pattern string) ([]string, error) {
-	if root == "" {
-		root = "."
-	}
 	if pattern == "" {
 		pattern = "*"
 	}
+	confinement, err := t.Confinement()
+	if err != nil {
+		return nil, err
+	}
+	root, err = confinement.Resolve(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listing does not read files, so size and binary filters don't apply
+	walker := workspace.NewWalker(root)
+	walker.MaxFileSize = 0
+	walker.SkipBinary = false
 
 	var files []string
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	err = walker.Walk(func(path string, info os.FileInfo) error {
+		// Skip symlinks leading out of the workspace
+		if _, err := confinement.Resolve(path); err != nil {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 		if matched, err := filepath.Match(pattern, filepath.Base(path)); err != nil {
 			return err
 		} else if matched {
-			files = append(files, path)
+			files = append(files, confinement.Rel(path))
 		}
 		return nil
 	})
@@ -90,7 +189,7 @@ func (t *File) searchFiles(query string, limit int) ([]types.SearchResult, error
 	return search.SearchCodebase(query, limit)
 }
 
-func (t *File) Execute(args map[string]interface{}) (interface{}, error) {
+func (t *File) Execute(args map[string]interface{}) (*types.ToolResult, error) {
 	operation, ok := args["operation"].(string)
 	if !ok {
 		return nil, fmt.Errorf("operation argument is required")
@@ -101,18 +200,98 @@ func (t *File) Execute(args map[string]interface{}) (interface{}, error) {
 		path = "."
 	}
 
-	var data []byte
-	if content, ok := args["content"].(string); ok {
-		data = []byte(content)
-	}
+	switch FileOperation(operation) {
+	case FileRead:
+		content, err := t.HandleFile(operation, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &types.ToolResult{
+			Content: []types.ContentBlock{{
+				Type:     types.BlockCode,
+				Text:     string(content),
+				Path:     path,
+				Language: languageForPath(path),
+			}},
+			Data: map[string]interface{}{"path": path, "size": len(content)},
+		}, nil
 
-	result, err := t.HandleFile(operation, path, data)
-	if err != nil {
-		return nil, err
+	case FileWrite, FileAppend, FileReplace, FileReplaceLines, FileInsert, FilePatch:
+		return t.executeEdit(FileOperation(operation), path, args)
+
+	case FileList:
+		pattern, _ := args["pattern"].(string)
+		files, err := t.listFiles(path, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return types.TextResult("No files found", files), nil
+		}
+		return types.TextResult(strings.Join(files, "\n"), files), nil
+
+	case FileSearch:
+		query, _ := args["query"].(string)
+		if query == "" {
+			query, _ = args["content"].(string)
+		}
+		results, err := t.searchFiles(query, 10)
+		if err != nil {
+			return nil, err
+		}
+		return searchResult(results), nil
+
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", operation)
 	}
+}
+
+// executeEdit runs one of the operations that change a file
+func (t *File) executeEdit(operation FileOperation, path string, args map[string]interface{}) (*types.ToolResult, error) {
+	content, _ := args["content"].(string)
+
+	switch operation {
+	case FileWrite:
+		return t.edit(path, true, func(string) (string, error) { return content, nil })
+
+	case FileAppend:
+		return t.edit(path, true, func(existing string) (string, error) { return existing + content, nil })
+
+	case FileReplace:
+		old, _ := args["old"].(string)
+		new, _ := args["new"].(string)
+		all, _ := args["replace_all"].(bool)
+		return t.edit(path, false, func(existing string) (string, error) {
+			return replaceText(existing, old, new, all)
+		})
+
+	case FileReplaceLines:
+		start, ok := args["start_line"].(int)
+		end, ok2 := args["end_line"].(int)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("replace_lines requires start_line and end_line")
+		}
+		return t.edit(path, false, func(existing string) (string, error) {
+			return replaceLines(existing, start, end, content)
+		})
+
+	case FileInsert:
+		line, ok := args["line"].(int)
+		if !ok {
+			return nil, fmt.Errorf("insert requires line")
+		}
+		return t.edit(path, false, func(existing string) (string, error) {
+			return insertLines(existing, line, content)
+		})
 
-	if op := FileOperation(operation); op == FileRead || op == FileList || op == FileSearch {
-		return string(result), nil
+	case FilePatch:
+		patch, _ := args["diff"].(string)
+		if patch == "" {
+			return nil, fmt.Errorf("patch requires diff")
+		}
+		return t.edit(path, true, func(existing string) (string, error) {
+			return diff.Apply(existing, patch)
+		})
 	}
-	return nil, nil
+	return nil, fmt.Errorf("unknown operation: %s", operation)
 }
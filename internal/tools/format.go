@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/azhany/codecli/internal/types"
+)
+
+// searchResult wraps search results in a tool result
+func searchResult(results []types.SearchResult) *types.ToolResult {
+	if len(results) == 0 {
+		return types.TextResult("No results found", results)
+	}
+	return types.TextResult(formatSearchResults(results), results)
+}
+
+// formatSearchResults lists search results one location per line, followed
+// by the matched chunk when it spans several lines
+func formatSearchResults(results []types.SearchResult) string {
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s:%d:", r.Path, r.Line)
+		if r.Symbol != "" {
+			fmt.Fprintf(&b, " [%s %s]", r.Kind, r.Symbol)
+		}
+		if strings.Contains(r.Content, "\n") {
+			fmt.Fprintf(&b, "\n%s\n", strings.TrimRight(r.Content, "\n"))
+		} else {
+			fmt.Fprintf(&b, " %s", strings.TrimSpace(r.Content))
+		}
+	}
+	return b.String()
+}
+
+// languages maps file extensions to the names used for fenced code blocks
+var languages = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".jsx":  "jsx",
+	".ts":   "typescript",
+	".tsx":  "tsx",
+	".java": "java",
+	".c":    "c",
+	".h":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".hpp":  "cpp",
+	".cs":   "csharp",
+	".php":  "php",
+	".rb":   "ruby",
+	".rs":   "rust",
+	".sh":   "sh",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".md":   "markdown",
+	".sql":  "sql",
+}
+
+func languageForPath(path string) string {
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
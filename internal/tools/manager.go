@@ -1,7 +1,11 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/azhany/codecli/internal/types"
 )
@@ -19,6 +23,7 @@ func NewManager() *Manager {
 
 	// Register default tools
 	m.RegisterTool(NewCommand())
+	m.RegisterTool(NewDefinitions())
 	m.RegisterTool(NewFile())
 
 	return m
@@ -38,11 +43,61 @@ func (m *Manager) GetTool(name string) (types.Tool, error) {
 	return tool, nil
 }
 
-// ListTools returns a list of all registered tools
+// ListTools returns a list of all registered tools, sorted by name
 func (m *Manager) ListTools() []types.Tool {
 	var tools []types.Tool
 	for _, tool := range m.tools {
 		tools = append(tools, tool)
 	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name() < tools[j].Name() })
 	return tools
 }
+
+// Execute validates args against the named tool's schema and runs the tool
+// with the coerced arguments. The returned result is never nil: failures are
+// reported as error results, and the error is also returned. Invalid
+// arguments yield a *ValidationError, whose result also describes the
+// arguments the tool accepts.
+func (m *Manager) Execute(name string, args map[string]interface{}) (*types.ToolResult, error) {
+	return m.ExecuteContext(context.Background(), name, args)
+}
+
+// ExecuteContext is like Execute, but cancels the call when ctx ends if the
+// tool supports it
+func (m *Manager) ExecuteContext(ctx context.Context, name string, args map[string]interface{}) (*types.ToolResult, error) {
+	start := time.Now()
+	result, err := m.execute(ctx, name, args)
+	if err != nil {
+		failed := types.ErrorResult(err)
+		if result != nil {
+			// Keep the output of the failed call ahead of the error
+			failed.Content = append(result.Content, failed.Content...)
+			failed.Data = result.Data
+			failed.Truncated = result.Truncated
+			failed.Metadata = result.Metadata
+		}
+		result = failed
+	}
+	if result == nil {
+		result = &types.ToolResult{}
+	}
+	result.Tool = name
+	result.SetMetadata("elapsed_ms", time.Since(start).Milliseconds())
+	return result, err
+}
+
+func (m *Manager) execute(ctx context.Context, name string, args map[string]interface{}) (*types.ToolResult, error) {
+	tool, err := m.GetTool(name)
+	if err != nil {
+		return nil, err
+	}
+	validated, err := ValidateArgs(name, tool.Schema(), args)
+	if err != nil {
+		schema, _ := json.Marshal(tool.Schema())
+		return nil, fmt.Errorf("%w\nThe %s tool accepts these arguments: %s", err, name, schema)
+	}
+	if tool, ok := tool.(types.ContextTool); ok {
+		return tool.ExecuteContext(ctx, validated)
+	}
+	return tool.Execute(validated)
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/azhany/codecli/internal/types"
+)
+
+// MaxPromptBytes caps the tool output sent back to the model, so that one
+// large file or command log cannot exhaust the context window
+const MaxPromptBytes = 16 * 1024
+
+const truncationNotice = "[output truncated]"
+
+// RenderTerminal formats a result for display in a terminal
+func RenderTerminal(result *types.ToolResult) string {
+	var b strings.Builder
+	if result.IsError {
+		b.WriteString("Error: ")
+	}
+	for i, block := range result.Content {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if block.Type == types.BlockCode && block.Path != "" && len(result.Content) > 1 {
+			fmt.Fprintf(&b, "==> %s <==\n", block.Path)
+		}
+		b.WriteString(strings.TrimRight(block.Text, "\n"))
+	}
+	if result.Truncated {
+		b.WriteString("\n" + truncationNotice)
+	}
+	return b.String()
+}
+
+// RenderJSON formats a result as indented JSON
+func RenderJSON(result *types.ToolResult) ([]byte, error) {
+	return json.MarshalIndent(result, "", "  ")
+}
+
+// RenderPrompt formats a result as the content of a tool message for the
+// model. Code is fenced and labelled with its path, errors are marked as
+// such, and output beyond MaxPromptBytes is cut with a notice.
+func RenderPrompt(result *types.ToolResult) string {
+	var b strings.Builder
+	if result.IsError {
+		b.WriteString("Error: ")
+	}
+	for i, block := range result.Content {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		switch block.Type {
+		case types.BlockCode:
+			if block.Path != "" {
+				fmt.Fprintf(&b, "File: %s\n", block.Path)
+			}
+			fmt.Fprintf(&b, "```%s\n%s\n```", block.Language, strings.TrimRight(block.Text, "\n"))
+		default:
+			b.WriteString(strings.TrimRight(block.Text, "\n"))
+		}
+	}
+	if b.Len() == 0 && !result.IsError {
+		b.WriteString("OK")
+	}
+
+	text := b.String()
+	truncated := result.Truncated
+	if len(text) > MaxPromptBytes {
+		// Drop a UTF-8 sequence cut in half
+		text = strings.ToValidUTF8(text[:MaxPromptBytes], "")
+		truncated = true
+	}
+	if truncated {
+		text += "\n" + truncationNotice
+	}
+	return text
+}
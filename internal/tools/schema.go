@@ -0,0 +1,300 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/azhany/codecli/internal/types"
+)
+
+// FieldError describes one invalid argument
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when tool arguments do not match the tool's
+// schema. It lists every problem so that a model can fix them all at once.
+type ValidationError struct {
+	Tool     string       `json:"tool"`
+	Problems []FieldError `json:"problems"`
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = p.Field + ": " + p.Message
+	}
+	return fmt.Sprintf("invalid arguments for tool %s: %s", e.Tool, strings.Join(problems, "; "))
+}
+
+// ValidateArgs checks args against a tool's object schema and returns a copy
+// with values coerced to the Go types tools expect: "integer" becomes int,
+// "number" float64, "boolean" bool and "string" string. Lenient conversions
+// cover what models commonly send, such as 10.0 or "10" for an integer and
+// "true" for a boolean.
+func ValidateArgs(tool string, schema *types.Schema, args map[string]interface{}) (map[string]interface{}, error) {
+	if schema == nil {
+		return args, nil
+	}
+
+	result, problems := validateObject(schema, args, "")
+	if len(problems) > 0 {
+		return nil, &ValidationError{Tool: tool, Problems: problems}
+	}
+	return result, nil
+}
+
+// validateObject validates the properties of an object, prefixing field
+// names in problems with prefix
+func validateObject(schema *types.Schema, args map[string]interface{}, prefix string) (map[string]interface{}, []FieldError) {
+	var problems []FieldError
+	result := make(map[string]interface{}, len(args))
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			msg := "unknown argument"
+			if suggestion := closestName(name, schema.Properties); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			problems = append(problems, FieldError{Field: prefix + name, Message: msg})
+			continue
+		}
+		if args[name] == nil {
+			continue // An explicit null is treated as omitted
+		}
+		if prop.Type == "object" && prop.Properties != nil {
+			obj, ok := args[name].(map[string]interface{})
+			if !ok {
+				problems = append(problems, FieldError{Field: prefix + name, Message: typeError("object", args[name]).Error()})
+				continue
+			}
+			value, nested := validateObject(prop, obj, prefix+name+".")
+			problems = append(problems, nested...)
+			result[name] = value
+			continue
+		}
+		value, err := coerce(prop, args[name])
+		if err != nil {
+			problems = append(problems, FieldError{Field: prefix + name, Message: err.Error()})
+			continue
+		}
+		result[name] = value
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := args[name]; !ok || args[name] == nil {
+			problems = append(problems, FieldError{Field: prefix + name, Message: "required argument is missing"})
+		}
+	}
+
+	return result, problems
+}
+
+// coerce converts value to the type described by schema
+func coerce(schema *types.Schema, value interface{}) (interface{}, error) {
+	var (
+		result interface{}
+		err    error
+	)
+	switch schema.Type {
+	case "string":
+		result, err = coerceString(value)
+	case "integer":
+		result, err = coerceInteger(value)
+	case "number":
+		result, err = coerceNumber(value)
+	case "boolean":
+		result, err = coerceBoolean(value)
+	case "array":
+		result, err = coerceArray(schema, value)
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return nil, typeError("object", value)
+		}
+		result = value
+	default:
+		result = value
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(schema.Enum) > 0 {
+		s, _ := result.(string)
+		for _, allowed := range schema.Enum {
+			if s == allowed {
+				return result, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %s, got %q", strings.Join(schema.Enum, ", "), s)
+	}
+	return result, nil
+}
+
+func coerceString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	}
+	return "", typeError("string", value)
+}
+
+func coerceInteger(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt32 {
+			return int(v), nil
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n), nil
+		}
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n, nil
+		}
+	}
+	return 0, typeError("integer", value)
+}
+
+func coerceNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f, nil
+		}
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, typeError("number", value)
+}
+
+func coerceBoolean(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b, nil
+		}
+	}
+	return false, typeError("boolean", value)
+}
+
+func coerceArray(schema *types.Schema, value interface{}) ([]interface{}, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		if strs, isStrings := value.([]string); isStrings {
+			for _, s := range strs {
+				items = append(items, s)
+			}
+		} else {
+			return nil, typeError("array", value)
+		}
+	}
+	if schema.Items == nil {
+		return items, nil
+	}
+
+	result := make([]interface{}, len(items))
+	for i, item := range items {
+		v, err := coerce(schema.Items, item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+func typeError(expected string, value interface{}) error {
+	return fmt.Errorf("expected %s, got %s", expected, describeValue(value))
+}
+
+// describeValue names a decoded JSON value's type for error messages
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case float64, int, int64, json.Number:
+		return fmt.Sprintf("number %v", v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// closestName suggests the known property nearest to a misspelled name
+func closestName(name string, properties map[string]*types.Schema) string {
+	best, bestDist := "", 3 // Suggest only within two edits
+	for known := range properties {
+		if d := editDistance(strings.ToLower(name), known); d < bestDist || (d == bestDist && known < best) {
+			best, bestDist = known, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
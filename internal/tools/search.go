@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+
+	"github.com/azhany/codecli/internal/config"
+	"github.com/azhany/codecli/internal/search"
+	"github.com/azhany/codecli/internal/types"
+	"github.com/azhany/codecli/internal/vector"
+	"github.com/azhany/codecli/internal/workspace"
+)
+
+type SearchOperation string
+
+const (
+	SearchIndex SearchOperation = "index"
+	SearchQuery SearchOperation = "search"
+)
+
+// Search handles indexing the workspace and searching it, either
+// semantically through the index or by keyword over the files themselves
+type Search struct {
+	*Base
+	store  *vector.VectorStore
+	loaded bool
+
+	once           sync.Once
+	confinement    *workspace.Confinement
+	confinementErr error
+}
+
+func NewSearch(store *vector.VectorStore) *Search {
+	return &Search{
+		Base:  NewBase("search", "Indexes the codebase and searches it by meaning or keyword (index/search)"),
+		store: store,
+	}
+}
+
+// Store returns the vector store holding the index
+func (t *Search) Store() *vector.VectorStore {
+	return t.store
+}
+
+func (t *Search) Schema() *types.Schema {
+	return &types.Schema{
+		Type: "object",
+		Properties: map[string]*types.Schema{
+			"operation": {
+				Type:        "string",
+				Description: "search the codebase, or (re)build the search index",
+				Enum:        []string{string(SearchQuery), string(SearchIndex)},
+			},
+			"query": {Type: "string", Description: "What to search for (search)"},
+			"type": {
+				Type:        "string",
+				Description: "semantic finds code by meaning, keyword by exact text or regex, both fuses semantic and identifier matches (search, default semantic)",
+				Enum:        []string{string(search.TypeSemantic), string(search.TypeKeyword), string(search.TypeBoth)},
+			},
+			"limit":       {Type: "integer", Description: "Maximum number of results (search, default 10)"},
+			"path":        {Type: "string", Description: "Directory to index or to keyword-search (default: workspace root)"},
+			"regex":       {Type: "boolean", Description: "Treat the query as a regular expression (keyword search)"},
+			"ignore_case": {Type: "boolean", Description: "Match case-insensitively (keyword search)"},
+			"context":     {Type: "integer", Description: "Lines of context around each match (keyword search)"},
+			"workers":     {Type: "integer", Description: "Concurrent indexing workers (index)"},
+			"batch_size":  {Type: "integer", Description: "Chunks per embedding request (index)"},
+			"rebuild":     {Type: "boolean", Description: "Discard the existing index and re-embed every file (index)"},
+		},
+		Required: []string{"operation"},
+	}
+}
+
+func (t *Search) Execute(args map[string]interface{}) (*types.ToolResult, error) {
+	operation, ok := args["operation"].(string)
+	if !ok {
+		return nil, fmt.Errorf("operation argument is required")
+	}
+
+	switch SearchOperation(operation) {
+	case SearchIndex:
+		opts := vector.IndexOptions{
+			Extensions: config.Config.Workspace.IncludeExtensions,
+		}
+		path, _ := args["path"].(string)
+		root, err := t.resolve(path)
+		if err != nil {
+			return nil, err
+		}
+		opts.Root = root
+		opts.Workers, _ = args["workers"].(int)
+		opts.BatchSize, _ = args["batch_size"].(int)
+		opts.Rebuild, _ = args["rebuild"].(bool)
+		return t.index(opts)
+	case SearchQuery:
+		query, ok := args["query"].(string)
+		if !ok || query == "" {
+			return nil, fmt.Errorf("query argument is required")
+		}
+		limit := 10
+		if l, ok := args["limit"].(int); ok && l > 0 {
+			limit = l
+		}
+		searchType := search.TypeSemantic
+		if s, ok := args["type"].(string); ok && s != "" {
+			parsed, err := search.ParseType(s)
+			if err != nil {
+				return nil, err
+			}
+			searchType = parsed
+		}
+
+		path, _ := args["path"].(string)
+		root, err := t.resolve(path)
+		if err != nil {
+			return nil, err
+		}
+		keyword := search.NewKeywordEngine(root)
+		keyword.Regex, _ = args["regex"].(bool)
+		keyword.IgnoreCase, _ = args["ignore_case"].(bool)
+		keyword.ContextLines, _ = args["context"].(int)
+
+		return t.search(query, limit, searchType, keyword)
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", operation)
+	}
+}
+
+// resolve checks that a directory given to the tool lies inside the
+// workspace and returns it relative to the workspace root setting, as
+// paths in the index and in results are. An empty path is the root.
+func (t *Search) resolve(path string) (string, error) {
+	t.once.Do(func() {
+		t.confinement, t.confinementErr = workspace.NewConfinement()
+	})
+	if t.confinementErr != nil {
+		return "", t.confinementErr
+	}
+	if _, err := t.confinement.Resolve(path); err != nil {
+		return "", err
+	}
+	switch {
+	case path == "":
+		return config.Config.Workspace.Root, nil
+	case filepath.IsAbs(path):
+		return path, nil
+	default:
+		return filepath.Join(config.Config.Workspace.Root, path), nil
+	}
+}
+
+func (t *Search) index(opts vector.IndexOptions) (*types.ToolResult, error) {
+	// Allow Ctrl-C to abort a long indexing run without corrupting the index
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stats, err := t.store.CreateIndex(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	t.loaded = true
+	return types.TextResult(fmt.Sprintf("Indexed %s: %s", opts.Root, stats), stats), nil
+}
+
+func (t *Search) search(query string, limit int, searchType search.Type, keyword *search.KeywordEngine) (*types.ToolResult, error) {
+	var engine search.Engine
+	switch searchType {
+	case search.TypeKeyword:
+		engine = keyword
+	case search.TypeSemantic:
+		if err := t.loadIndex(); err != nil {
+			return nil, err
+		}
+		engine = t.store
+	case search.TypeBoth:
+		if err := t.loadIndex(); err != nil {
+			return nil, err
+		}
+		engine = search.NewHybridEngine(t.store, search.EngineFunc(t.store.SearchLexical))
+	}
+
+	results, err := engine.Search(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return searchResult(results), nil
+}
+
+// loadIndex loads the index from disk the first time it is needed
+func (t *Search) loadIndex() error {
+	if !t.loaded {
+		if err := t.store.LoadIndex(); err != nil {
+			if errors.Is(err, vector.ErrIndexNotFound) {
+				return fmt.Errorf("failed to load index (run `codecli index` first, or use --type keyword): %v", err)
+			}
+			return fmt.Errorf("failed to load index: %v", err)
+		}
+		t.loaded = true
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package types
+
+import "fmt"
+
+// Content block types
+const (
+	BlockText = "text"
+	BlockCode = "code" // File content; Path and Language describe it
+)
+
+// ContentBlock is one piece of a tool's output
+type ContentBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Path     string `json:"path,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// ToolResult is the outcome of a tool call. Content is meant for people and
+// models to read; Data carries the same result in structured form.
+type ToolResult struct {
+	Tool      string                 `json:"tool,omitempty"`
+	Content   []ContentBlock         `json:"content"`
+	Data      interface{}            `json:"data,omitempty"`
+	Truncated bool                   `json:"truncated,omitempty"` // Content was cut short
+	IsError   bool                   `json:"is_error,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"` // e.g. "elapsed_ms"
+}
+
+// TextResult creates a result with a single text block
+func TextResult(text string, data interface{}) *ToolResult {
+	return &ToolResult{
+		Content: []ContentBlock{{Type: BlockText, Text: text}},
+		Data:    data,
+	}
+}
+
+// ErrorResult creates a result reporting a failed tool call
+func ErrorResult(err error) *ToolResult {
+	return &ToolResult{
+		Content: []ContentBlock{{Type: BlockText, Text: err.Error()}},
+		IsError: true,
+	}
+}
+
+// SetMetadata records a metadata value on the result
+func (r *ToolResult) SetMetadata(key string, value interface{}) {
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]interface{})
+	}
+	r.Metadata[key] = value
+}
+
+// Text returns the text of all content blocks joined by newlines
+func (r *ToolResult) Text() string {
+	text := ""
+	for i, block := range r.Content {
+		if i > 0 {
+			text += "\n"
+		}
+		text += block.Text
+	}
+	return text
+}
+
+func (r *ToolResult) String() string {
+	if r.IsError {
+		return fmt.Sprintf("error: %s", r.Text())
+	}
+	return r.Text()
+}
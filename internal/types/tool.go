@@ -1,11 +1,33 @@
 ﻿// Package types provides core interfaces and types for the CLI tools
 package types
 
+import "context"
+
 // Tool represents a tool that can be called by the LLM
 type Tool interface {
     Name() string
     Description() string
-    Execute(args map[string]interface{}) (interface{}, error)
+    Schema() *Schema // Arguments accepted by Execute
+    // Execute runs the tool. A result returned along with an error holds
+    // whatever output the failed call produced.
+    Execute(args map[string]interface{}) (*ToolResult, error)
+}
+
+// ContextTool is a Tool whose calls can be cancelled, such as one running
+// external processes
+type ContextTool interface {
+    Tool
+    ExecuteContext(ctx context.Context, args map[string]interface{}) (*ToolResult, error)
+}
+
+// Schema is the subset of JSON Schema used to describe tool arguments
+type Schema struct {
+    Type        string             `json:"type"`
+    Description string             `json:"description,omitempty"`
+    Properties  map[string]*Schema `json:"properties,omitempty"`
+    Required    []string           `json:"required,omitempty"`
+    Enum        []string           `json:"enum,omitempty"`
+    Items       *Schema            `json:"items,omitempty"`
 }
 
 // FileHandler handles file operations like read, write, list, and search
@@ -22,10 +44,19 @@ type CommandRunner interface {
 
 // SearchResult represents a single search result
 type SearchResult struct {
-    Path     string
-    Line     int
-    Content  string
-    Distance float64
+    Path     string   `json:"path"`
+    Line     int      `json:"line"`
+    Content  string   `json:"content"`
+    Symbol   string   `json:"symbol,omitempty"` // Enclosing declaration, e.g. "VectorStore.Search"
+    Kind     string   `json:"kind,omitempty"`   // Declaration kind, e.g. "method"
+    Distance float64  `json:"distance,omitempty"`
+    Before   []string `json:"before,omitempty"` // Context lines preceding Line (keyword search)
+    After    []string `json:"after,omitempty"`  // Context lines following Line (keyword search)
+
+    // Ranking scores of a hybrid search; Score is the fused score
+    Score         float64 `json:"score,omitempty"`
+    SemanticScore float64 `json:"semantic_score,omitempty"` // Cosine similarity to the query embedding
+    LexicalScore  float64 `json:"lexical_score,omitempty"`  // BM25 score of the query terms
 }
 
 // ToolFactory creates tool instances
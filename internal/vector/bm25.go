@@ -0,0 +1,249 @@
+package vector
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 parameters: k1 controls term frequency saturation and b the strength
+// of document length normalization
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// LexicalIndex is an inverted index over chunk text scored with BM25. It
+// complements the vector index with exact identifier matches.
+type LexicalIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[uint32]uint32 // term -> chunk ID -> term frequency
+	lengths  map[uint32]int               // chunk ID -> number of terms
+	terms    map[uint32][]string          // chunk ID -> distinct terms, for removal
+	total    int                          // Sum of all lengths
+}
+
+// NewLexicalIndex creates an empty lexical index
+func NewLexicalIndex() *LexicalIndex {
+	return &LexicalIndex{
+		postings: make(map[string]map[uint32]uint32),
+		lengths:  make(map[uint32]int),
+		terms:    make(map[uint32][]string),
+	}
+}
+
+// Add indexes the text of a chunk, replacing any previous text for id
+func (l *LexicalIndex) Add(id uint32, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.remove(id)
+
+	tokens := tokenize(text)
+	freqs := make(map[string]uint32)
+	for _, token := range tokens {
+		freqs[token]++
+	}
+
+	terms := make([]string, 0, len(freqs))
+	for term, tf := range freqs {
+		docs, ok := l.postings[term]
+		if !ok {
+			docs = make(map[uint32]uint32)
+			l.postings[term] = docs
+		}
+		docs[id] = tf
+		terms = append(terms, term)
+	}
+	l.terms[id] = terms
+	l.lengths[id] = len(tokens)
+	l.total += len(tokens)
+}
+
+// Remove drops a chunk from the index
+func (l *LexicalIndex) Remove(id uint32) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.remove(id)
+}
+
+func (l *LexicalIndex) remove(id uint32) {
+	length, ok := l.lengths[id]
+	if !ok {
+		return
+	}
+	for _, term := range l.terms[id] {
+		docs := l.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(l.postings, term)
+		}
+	}
+	delete(l.terms, id)
+	delete(l.lengths, id)
+	l.total -= length
+}
+
+// Search returns the k chunks with the highest BM25 score for the query.
+// Neighbor.Score holds the BM25 score; chunks matching no term are omitted.
+func (l *LexicalIndex) Search(query string, k int) []Neighbor {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if len(l.lengths) == 0 || k <= 0 {
+		return nil
+	}
+
+	n := float64(len(l.lengths))
+	avgLen := float64(l.total) / n
+	scores := make(map[uint32]float64)
+
+	seen := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		docs := l.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range docs {
+			f := float64(tf)
+			norm := 1 - bm25B + bm25B*float64(l.lengths[id])/avgLen
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+	}
+
+	neighbors := make([]Neighbor, 0, len(scores))
+	for id, score := range scores {
+		neighbors = append(neighbors, Neighbor{ID: id, Score: score})
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].Score != neighbors[j].Score {
+			return neighbors[i].Score > neighbors[j].Score
+		}
+		return neighbors[i].ID < neighbors[j].ID
+	})
+	if len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
+
+// Len returns the number of indexed chunks
+func (l *LexicalIndex) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.lengths)
+}
+
+// lexicalSnapshot is the persisted inverted index
+type lexicalSnapshot struct {
+	Postings map[string]map[uint32]uint32
+	Lengths  map[uint32]int
+}
+
+// Save writes the inverted index
+func (l *LexicalIndex) Save(w io.Writer) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return gob.NewEncoder(w).Encode(&lexicalSnapshot{Postings: l.postings, Lengths: l.lengths})
+}
+
+// Load restores an index written by Save. Like the HNSW graph, a stale
+// snapshot is repaired: chunks missing from texts are dropped and chunks
+// missing from the snapshot are indexed from texts.
+func (l *LexicalIndex) Load(r io.Reader, texts map[uint32]string) error {
+	var snapshot lexicalSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("failed to decode lexical index: %v", err)
+	}
+
+	l.mu.Lock()
+	l.postings = make(map[string]map[uint32]uint32, len(snapshot.Postings))
+	l.lengths = make(map[uint32]int, len(snapshot.Lengths))
+	l.terms = make(map[uint32][]string, len(snapshot.Lengths))
+	l.total = 0
+	for term, docs := range snapshot.Postings {
+		for id, tf := range docs {
+			if _, ok := texts[id]; !ok {
+				continue
+			}
+			if l.postings[term] == nil {
+				l.postings[term] = make(map[uint32]uint32)
+			}
+			l.postings[term][id] = tf
+			l.terms[id] = append(l.terms[id], term)
+		}
+	}
+	for id, length := range snapshot.Lengths {
+		if _, ok := texts[id]; ok {
+			l.lengths[id] = length
+			l.total += length
+		}
+	}
+	l.mu.Unlock()
+
+	for id, text := range texts {
+		if _, ok := snapshot.Lengths[id]; !ok {
+			l.Add(id, text)
+		}
+	}
+	return nil
+}
+
+// tokenize splits text into lower-cased identifier tokens. Compound
+// identifiers are indexed both whole and by their parts, so "LoadIndex"
+// matches queries for "LoadIndex", "load" and "index".
+func tokenize(text string) []string {
+	var tokens []string
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		if len(word) > 1 {
+			tokens = append(tokens, strings.ToLower(word))
+		}
+		if len(parts) > 1 {
+			for _, part := range parts {
+				if len(part) > 1 {
+					tokens = append(tokens, strings.ToLower(part))
+				}
+			}
+		}
+	}
+	return tokens
+}
+
+// splitIdentifier splits camelCase, PascalCase and snake_case identifiers,
+// keeping acronyms together ("HTTPServer" -> "HTTP", "Server")
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, piece := range strings.Split(word, "_") {
+		runes := []rune(piece)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			if (unicode.IsLower(prev) && unicode.IsUpper(cur)) ||
+				(unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) ||
+				unicode.IsDigit(prev) != unicode.IsDigit(cur) {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
@@ -0,0 +1,451 @@
+package vector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// ErrIncompatibleIndex is returned when an index on disk cannot be used by
+// this build or configuration
+var ErrIncompatibleIndex = errors.New("incompatible index")
+
+// FormatVersion is the on-disk index format written by this build.
+// Version 0 is the legacy single metadata.json layout.
+const FormatVersion = 1
+
+// Files making up an index directory
+const (
+	vectorsFile        = "vectors.bin"
+	metadataFile       = "metadata.gob"
+	lexicalFile        = "lexical.gob"
+	legacyMetadataFile = "metadata.json"
+)
+
+// vectorMagic identifies a vectors.bin file
+var vectorMagic = [8]byte{'C', 'C', 'L', 'I', 'V', 'E', 'C', 0}
+
+// VectorEncoding selects how vectors are packed in vectors.bin
+type VectorEncoding uint32
+
+const (
+	EncodingFloat32 VectorEncoding = iota
+	EncodingFloat16
+	EncodingInt8
+)
+
+// ParseVectorEncoding maps the ngt.quantization setting to an encoding
+func ParseVectorEncoding(name string) (VectorEncoding, error) {
+	switch name {
+	case "", "none", "float32":
+		return EncodingFloat32, nil
+	case "float16":
+		return EncodingFloat16, nil
+	case "int8":
+		return EncodingInt8, nil
+	default:
+		return 0, fmt.Errorf("unknown quantization: %s", name)
+	}
+}
+
+func (e VectorEncoding) String() string {
+	switch e {
+	case EncodingFloat32:
+		return "float32"
+	case EncodingFloat16:
+		return "float16"
+	case EncodingInt8:
+		return "int8"
+	default:
+		return fmt.Sprintf("encoding(%d)", uint32(e))
+	}
+}
+
+// IndexHeader describes the vectors stored in an index
+type IndexHeader struct {
+	Version   uint32
+	Encoding  VectorEncoding
+	Dimension int
+	Count     int
+	Model     string
+}
+
+// indexMetadata is the content of metadata.gob
+type indexMetadata struct {
+	Version uint32
+	Files   []*FileMetadata
+}
+
+// vectorFile is a decoded vectors.bin. When the file holds float32 data on
+// a little-endian host, vectors alias the (possibly memory-mapped) file data
+// instead of being copied.
+type vectorFile struct {
+	header  IndexHeader
+	ids     []uint32
+	vectors [][]float32
+	release func() error
+}
+
+// Layout of vectors.bin (all integers little-endian):
+//
+//	magic     [8]byte
+//	version   uint32
+//	encoding  uint32
+//	dimension uint32
+//	count     uint32
+//	modelLen  uint32
+//	model     [modelLen]byte
+//	padding to an 8-byte boundary
+//	ids       [count]uint32
+//	padding to an 8-byte boundary
+//	data      float32: [count*dimension]float32
+//	          float16: [count*dimension]uint16
+//	          int8:    [count]float32 scales, then [count*dimension]int8
+func writeVectorFile(path string, header IndexHeader, ids []uint32, vectors [][]float32) error {
+	var buf bytes.Buffer
+	buf.Write(vectorMagic[:])
+	fields := []uint32{header.Version, uint32(header.Encoding), uint32(header.Dimension), uint32(len(ids)), uint32(len(header.Model))}
+	if err := binary.Write(&buf, binary.LittleEndian, fields); err != nil {
+		return err
+	}
+	buf.WriteString(header.Model)
+	pad8(&buf)
+	if err := binary.Write(&buf, binary.LittleEndian, ids); err != nil {
+		return err
+	}
+	pad8(&buf)
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		return encodeVectors(w, header.Encoding, header.Dimension, vectors)
+	})
+}
+
+func pad8(buf *bytes.Buffer) {
+	for buf.Len()%8 != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+func encodeVectors(w io.Writer, encoding VectorEncoding, dim int, vectors [][]float32) error {
+	bw := bufio.NewWriter(w)
+	for _, vec := range vectors {
+		if len(vec) != dim {
+			return fmt.Errorf("vector has dimension %d, expected %d", len(vec), dim)
+		}
+	}
+
+	switch encoding {
+	case EncodingFloat32:
+		for _, vec := range vectors {
+			if err := binary.Write(bw, binary.LittleEndian, vec); err != nil {
+				return err
+			}
+		}
+	case EncodingFloat16:
+		half := make([]uint16, dim)
+		for _, vec := range vectors {
+			for i, x := range vec {
+				half[i] = float32ToHalf(x)
+			}
+			if err := binary.Write(bw, binary.LittleEndian, half); err != nil {
+				return err
+			}
+		}
+	case EncodingInt8:
+		scales := make([]float32, len(vectors))
+		for i, vec := range vectors {
+			scales[i] = int8Scale(vec)
+		}
+		if err := binary.Write(bw, binary.LittleEndian, scales); err != nil {
+			return err
+		}
+		quantized := make([]int8, dim)
+		for i, vec := range vectors {
+			for j, x := range vec {
+				quantized[j] = quantizeInt8(x, scales[i])
+			}
+			if err := binary.Write(bw, binary.LittleEndian, quantized); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported vector encoding: %s", encoding)
+	}
+
+	return bw.Flush()
+}
+
+// readVectorFile maps and decodes a vectors.bin written by writeVectorFile
+func readVectorFile(path string) (*vectorFile, error) {
+	data, release, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vf, aliased, err := decodeVectorFile(data)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	if aliased {
+		vf.release = release
+		return vf, nil
+	}
+
+	// Nothing points into the mapping any more
+	if err := release(); err != nil {
+		return nil, err
+	}
+	vf.release = func() error { return nil }
+	return vf, nil
+}
+
+// decodeVectorFile parses vectors.bin, reporting whether the returned vectors
+// alias data
+func decodeVectorFile(data []byte) (*vectorFile, bool, error) {
+	const fixedLen = 8 + 5*4
+	if len(data) < fixedLen || !bytes.Equal(data[:8], vectorMagic[:]) {
+		return nil, false, fmt.Errorf("not a vector index file")
+	}
+
+	le := binary.LittleEndian
+	header := IndexHeader{
+		Version:   le.Uint32(data[8:]),
+		Encoding:  VectorEncoding(le.Uint32(data[12:])),
+		Dimension: int(le.Uint32(data[16:])),
+		Count:     int(le.Uint32(data[20:])),
+	}
+	if header.Version != FormatVersion {
+		return nil, false, incompatibleIndex("vectors file has format version %d, this build reads version %d",
+			header.Version, FormatVersion)
+	}
+
+	modelLen := int(le.Uint32(data[24:]))
+	off := fixedLen
+	if len(data) < off+modelLen {
+		return nil, false, fmt.Errorf("truncated vector index header")
+	}
+	header.Model = string(data[off : off+modelLen])
+	off = align8(off + modelLen)
+
+	count, dim := header.Count, header.Dimension
+	if len(data) < off+4*count {
+		return nil, false, fmt.Errorf("truncated vector index ids")
+	}
+	ids := make([]uint32, count)
+	for i := range ids {
+		ids[i] = le.Uint32(data[off+4*i:])
+	}
+	off = align8(off + 4*count)
+
+	vf := &vectorFile{header: header, ids: ids, vectors: make([][]float32, count)}
+	aliased := false
+	switch header.Encoding {
+	case EncodingFloat32:
+		if len(data) < off+4*count*dim {
+			return nil, false, fmt.Errorf("truncated vector data")
+		}
+		raw := data[off : off+4*count*dim]
+		if count*dim > 0 && hostLittleEndian() && uintptr(unsafe.Pointer(&raw[0]))%4 == 0 {
+			all := unsafe.Slice((*float32)(unsafe.Pointer(&raw[0])), count*dim)
+			for i := range vf.vectors {
+				vf.vectors[i] = all[i*dim : (i+1)*dim : (i+1)*dim]
+			}
+			aliased = true
+		} else {
+			for i := range vf.vectors {
+				vec := make([]float32, dim)
+				for j := range vec {
+					vec[j] = math.Float32frombits(le.Uint32(raw[4*(i*dim+j):]))
+				}
+				vf.vectors[i] = vec
+			}
+		}
+	case EncodingFloat16:
+		if len(data) < off+2*count*dim {
+			return nil, false, fmt.Errorf("truncated vector data")
+		}
+		for i := range vf.vectors {
+			vec := make([]float32, dim)
+			for j := range vec {
+				vec[j] = halfToFloat32(le.Uint16(data[off+2*(i*dim+j):]))
+			}
+			vf.vectors[i] = vec
+		}
+	case EncodingInt8:
+		if len(data) < off+4*count+count*dim {
+			return nil, false, fmt.Errorf("truncated vector data")
+		}
+		scales := data[off : off+4*count]
+		quantized := data[off+4*count:]
+		for i := range vf.vectors {
+			scale := math.Float32frombits(le.Uint32(scales[4*i:]))
+			vec := make([]float32, dim)
+			for j := range vec {
+				vec[j] = float32(int8(quantized[i*dim+j])) * scale
+			}
+			vf.vectors[i] = vec
+		}
+	default:
+		return nil, false, fmt.Errorf("unsupported vector encoding: %s", header.Encoding)
+	}
+
+	return vf, aliased, nil
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+func hostLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
+
+// writeMetadataFile writes metadata.gob
+func writeMetadataFile(path string, files []*FileMetadata) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(&indexMetadata{Version: FormatVersion, Files: files})
+	})
+}
+
+// readMetadataFile reads metadata.gob
+func readMetadataFile(path string) (*indexMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var meta indexMetadata
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %v", err)
+	}
+	return &meta, nil
+}
+
+// writeFileAtomic writes a file through a temporary sibling and renames it
+// into place, so readers (and existing memory mappings) never observe a
+// partially written file
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// int8Scale returns the symmetric quantization scale for a vector
+func int8Scale(vec []float32) float32 {
+	var maxAbs float32
+	for _, x := range vec {
+		if x < 0 {
+			x = -x
+		}
+		if x > maxAbs {
+			maxAbs = x
+		}
+	}
+	if maxAbs == 0 {
+		return 1
+	}
+	return maxAbs / 127
+}
+
+func quantizeInt8(x, scale float32) int8 {
+	q := math.Round(float64(x / scale))
+	if q > 127 {
+		q = 127
+	} else if q < -127 {
+		q = -127
+	}
+	return int8(q)
+}
+
+// float32ToHalf converts to IEEE 754 binary16, rounding to nearest even
+func float32ToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int((bits>>23)&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+
+	switch {
+	case (bits>>23)&0xff == 0xff: // Inf or NaN
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp >= 0x1f: // Overflow
+		return sign | 0x7c00
+	case exp <= 0: // Subnormal or zero
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		mid := uint32(1) << (shift - 1)
+		if rem > mid || (rem == mid && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	half := uint32(exp)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++ // May carry into the exponent, which is still correct
+	}
+	return sign | uint16(half)
+}
+
+// halfToFloat32 converts from IEEE 754 binary16
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch {
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case exp == 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Normalize the subnormal
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		mant &= 0x3ff
+		return math.Float32frombits(sign | e<<23 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
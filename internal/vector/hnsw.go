@@ -0,0 +1,525 @@
+package vector
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// HNSWParams configures the hierarchical navigable small world graph
+type HNSWParams struct {
+	M              int // Maximum links per node on upper layers (layer 0 allows 2*M)
+	EfConstruction int // Candidate list size while inserting
+	EfSearch       int // Candidate list size while querying
+}
+
+func (p HNSWParams) withDefaults() HNSWParams {
+	if p.M <= 1 {
+		p.M = 16
+	}
+	if p.EfConstruction <= 0 {
+		p.EfConstruction = 200
+	}
+	if p.EfSearch <= 0 {
+		p.EfSearch = 64
+	}
+	return p
+}
+
+// HNSWIndex is an approximate nearest-neighbour index based on
+// Malkov & Yashunin's hierarchical navigable small world graphs
+type HNSWIndex struct {
+	params    HNSWParams
+	levelMult float64
+	nodes     map[uint32]*hnswNode
+	entry     uint32
+	maxLevel  int
+	rng       *rand.Rand
+	mu        sync.RWMutex
+}
+
+type hnswNode struct {
+	id     uint32
+	vector []float32
+	norm   float64
+	links  [][]uint32 // Neighbour IDs per layer, 0 is the densest
+}
+
+// NewHNSWIndex creates an empty HNSW index
+func NewHNSWIndex(params HNSWParams) *HNSWIndex {
+	params = params.withDefaults()
+	return &HNSWIndex{
+		params:    params,
+		levelMult: 1 / math.Log(float64(params.M)),
+		nodes:     make(map[uint32]*hnswNode),
+		maxLevel:  -1,
+		// A fixed seed keeps graphs reproducible across runs
+		rng: rand.New(rand.NewSource(1)),
+	}
+}
+
+func newHNSWNode(id uint32, vector []float32, level int) *hnswNode {
+	var norm float64
+	for _, x := range vector {
+		norm += float64(x) * float64(x)
+	}
+	return &hnswNode{
+		id:     id,
+		vector: vector,
+		norm:   math.Sqrt(norm),
+		links:  make([][]uint32, level+1),
+	}
+}
+
+// distance returns the cosine distance between a query and a node
+func (h *HNSWIndex) distance(query []float32, queryNorm float64, node *hnswNode) float64 {
+	if len(query) != len(node.vector) || queryNorm == 0 || node.norm == 0 {
+		return 1
+	}
+	var dot float64
+	for i := range query {
+		dot += float64(query[i]) * float64(node.vector[i])
+	}
+	return 1 - dot/(queryNorm*node.norm)
+}
+
+func (h *HNSWIndex) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * h.params.M
+	}
+	return h.params.M
+}
+
+func (h *HNSWIndex) randomLevel() int {
+	return int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+}
+
+func (h *HNSWIndex) Add(id uint32, vector []float32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.nodes[id]; ok {
+		h.remove(id)
+	}
+
+	node := newHNSWNode(id, vector, h.randomLevel())
+	h.insert(node)
+	return nil
+}
+
+// insert links a node whose level is already fixed into the graph
+func (h *HNSWIndex) insert(node *hnswNode) {
+	level := len(node.links) - 1
+	h.nodes[node.id] = node
+
+	if h.maxLevel < 0 {
+		h.entry = node.id
+		h.maxLevel = level
+		return
+	}
+
+	// Greedy descent through the layers above the node's own level
+	ep := h.nodes[h.entry]
+	epDist := h.distance(node.vector, node.norm, ep)
+	for l := h.maxLevel; l > level; l-- {
+		ep, epDist = h.greedyClosest(node.vector, node.norm, ep, epDist, l)
+	}
+
+	entries := []candidate{{node: ep, dist: epDist}}
+	for l := minInt(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(node.vector, node.norm, entries, h.params.EfConstruction, l)
+		neighbors := h.selectNeighbors(found, h.params.M)
+
+		node.links[l] = make([]uint32, 0, len(neighbors))
+		for _, c := range neighbors {
+			node.links[l] = append(node.links[l], c.node.id)
+			h.link(c.node, node.id, l)
+		}
+		entries = found
+	}
+
+	if level > h.maxLevel {
+		h.entry = node.id
+		h.maxLevel = level
+	}
+}
+
+// link adds a back-link from node to id on layer l, pruning if it overflows
+func (h *HNSWIndex) link(node *hnswNode, id uint32, l int) {
+	node.links[l] = append(node.links[l], id)
+	if len(node.links[l]) <= h.maxLinks(l) {
+		return
+	}
+
+	candidates := make([]candidate, 0, len(node.links[l]))
+	for _, nid := range node.links[l] {
+		if n, ok := h.nodes[nid]; ok {
+			candidates = append(candidates, candidate{node: n, dist: h.distance(node.vector, node.norm, n)})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+
+	kept := h.selectNeighbors(candidates, h.maxLinks(l))
+	node.links[l] = node.links[l][:0]
+	for _, c := range kept {
+		node.links[l] = append(node.links[l], c.node.id)
+	}
+}
+
+// selectNeighbors applies the HNSW neighbour-selection heuristic to
+// candidates sorted by ascending distance, preferring diverse directions and
+// topping up with the closest remaining candidates
+func (h *HNSWIndex) selectNeighbors(candidates []candidate, m int) []candidate {
+	if len(candidates) <= m {
+		return candidates
+	}
+
+	selected := make([]candidate, 0, m)
+	var skipped []candidate
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		good := true
+		for _, s := range selected {
+			if h.distance(c.node.vector, c.node.norm, s.node) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	for _, c := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// greedyClosest walks layer l towards the query until no neighbour is closer
+func (h *HNSWIndex) greedyClosest(query []float32, queryNorm float64, ep *hnswNode, epDist float64, l int) (*hnswNode, float64) {
+	for changed := true; changed; {
+		changed = false
+		for _, nid := range ep.links[l] {
+			n, ok := h.nodes[nid]
+			if !ok {
+				continue
+			}
+			if d := h.distance(query, queryNorm, n); d < epDist {
+				ep, epDist = n, d
+				changed = true
+			}
+		}
+	}
+	return ep, epDist
+}
+
+// searchLayer returns up to ef nodes on layer l closest to the query,
+// sorted by ascending distance
+func (h *HNSWIndex) searchLayer(query []float32, queryNorm float64, entries []candidate, ef int, l int) []candidate {
+	visited := make(map[uint32]bool, ef*4)
+	candidates := &minHeap{}
+	results := &maxHeap{}
+
+	for _, e := range entries {
+		visited[e.node.id] = true
+		heap.Push(candidates, e)
+		heap.Push(results, e)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.dist > (*results)[0].dist {
+			break
+		}
+		if l >= len(c.node.links) {
+			continue
+		}
+		for _, nid := range c.node.links[l] {
+			if visited[nid] {
+				continue
+			}
+			visited[nid] = true
+
+			n, ok := h.nodes[nid]
+			if !ok {
+				continue
+			}
+			d := h.distance(query, queryNorm, n)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, candidate{node: n, dist: d})
+				heap.Push(results, candidate{node: n, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := make([]candidate, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(candidate)
+	}
+	return sorted
+}
+
+func (h *HNSWIndex) Remove(id uint32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(id)
+	return nil
+}
+
+// remove unlinks a node and reconnects its former neighbours among each other
+func (h *HNSWIndex) remove(id uint32) {
+	node, ok := h.nodes[id]
+	if !ok {
+		return
+	}
+	delete(h.nodes, id)
+
+	for l, links := range node.links {
+		for _, nid := range links {
+			n, ok := h.nodes[nid]
+			if !ok || l >= len(n.links) {
+				continue
+			}
+
+			// Drop the link to the removed node and offer the removed node's
+			// other neighbours as replacements
+			pool := make(map[uint32]bool)
+			for _, x := range n.links[l] {
+				if x != id {
+					pool[x] = true
+				}
+			}
+			for _, x := range links {
+				if x != nid && x != id {
+					pool[x] = true
+				}
+			}
+
+			candidates := make([]candidate, 0, len(pool))
+			for x := range pool {
+				if m, ok := h.nodes[x]; ok && l < len(m.links) {
+					candidates = append(candidates, candidate{node: m, dist: h.distance(n.vector, n.norm, m)})
+				}
+			}
+			sort.Slice(candidates, func(i, j int) bool {
+				if candidates[i].dist != candidates[j].dist {
+					return candidates[i].dist < candidates[j].dist
+				}
+				return candidates[i].node.id < candidates[j].node.id
+			})
+
+			kept := h.selectNeighbors(candidates, h.maxLinks(l))
+			n.links[l] = n.links[l][:0]
+			for _, c := range kept {
+				n.links[l] = append(n.links[l], c.node.id)
+			}
+		}
+	}
+
+	if h.entry == id {
+		h.resetEntry()
+	}
+}
+
+// resetEntry picks the highest-level remaining node as the entry point
+func (h *HNSWIndex) resetEntry() {
+	h.maxLevel = -1
+	for nid, n := range h.nodes {
+		level := len(n.links) - 1
+		if level > h.maxLevel || (level == h.maxLevel && nid < h.entry) {
+			h.entry = nid
+			h.maxLevel = level
+		}
+	}
+}
+
+func (h *HNSWIndex) Search(query []float32, k int) ([]Neighbor, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.maxLevel < 0 || k <= 0 {
+		return nil, nil
+	}
+
+	var queryNorm float64
+	for _, x := range query {
+		queryNorm += float64(x) * float64(x)
+	}
+	queryNorm = math.Sqrt(queryNorm)
+
+	ep := h.nodes[h.entry]
+	epDist := h.distance(query, queryNorm, ep)
+	for l := h.maxLevel; l > 0; l-- {
+		ep, epDist = h.greedyClosest(query, queryNorm, ep, epDist, l)
+	}
+
+	ef := h.params.EfSearch
+	if k > ef {
+		ef = k
+	}
+	found := h.searchLayer(query, queryNorm, []candidate{{node: ep, dist: epDist}}, ef, 0)
+	if len(found) > k {
+		found = found[:k]
+	}
+
+	neighbors := make([]Neighbor, len(found))
+	for i, c := range found {
+		neighbors[i] = Neighbor{ID: c.node.id, Score: 1 - c.dist}
+	}
+	return neighbors, nil
+}
+
+func (h *HNSWIndex) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.nodes)
+}
+
+// hnswSnapshot is the persisted graph structure
+type hnswSnapshot struct {
+	Params   HNSWParams
+	Entry    uint32
+	MaxLevel int
+	IDs      []uint32
+	Links    [][][]uint32
+}
+
+func (h *HNSWIndex) Save(w io.Writer) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	snapshot := hnswSnapshot{
+		Params:   h.params,
+		Entry:    h.entry,
+		MaxLevel: h.maxLevel,
+		IDs:      make([]uint32, 0, len(h.nodes)),
+		Links:    make([][][]uint32, 0, len(h.nodes)),
+	}
+	for id, node := range h.nodes {
+		snapshot.IDs = append(snapshot.IDs, id)
+		snapshot.Links = append(snapshot.Links, node.links)
+	}
+
+	return gob.NewEncoder(w).Encode(&snapshot)
+}
+
+// Load restores a graph written by Save. Vectors missing from the snapshot
+// are inserted afresh and snapshot nodes without a vector are dropped, so a
+// stale graph is repaired rather than rejected.
+func (h *HNSWIndex) Load(r io.Reader, vectors map[uint32][]float32) error {
+	var snapshot hnswSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("failed to decode HNSW graph: %v", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nodes = make(map[uint32]*hnswNode, len(snapshot.IDs))
+	h.entry = snapshot.Entry
+	h.maxLevel = snapshot.MaxLevel
+
+	for i, id := range snapshot.IDs {
+		vector, ok := vectors[id]
+		if !ok {
+			continue
+		}
+		node := newHNSWNode(id, vector, len(snapshot.Links[i])-1)
+		node.links = snapshot.Links[i]
+		h.nodes[id] = node
+	}
+
+	if len(h.nodes) != len(snapshot.IDs) || h.nodes[h.entry] == nil {
+		h.pruneDangling()
+	}
+
+	var missing []uint32
+	for id := range vectors {
+		if _, ok := h.nodes[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	for _, id := range missing {
+		h.insert(newHNSWNode(id, vectors[id], h.randomLevel()))
+	}
+
+	return nil
+}
+
+// pruneDangling drops links to nodes that no longer exist and repairs the entry point
+func (h *HNSWIndex) pruneDangling() {
+	for _, node := range h.nodes {
+		for l, links := range node.links {
+			kept := links[:0]
+			for _, nid := range links {
+				if _, ok := h.nodes[nid]; ok {
+					kept = append(kept, nid)
+				}
+			}
+			node.links[l] = kept
+		}
+	}
+	if _, ok := h.nodes[h.entry]; !ok {
+		h.resetEntry()
+	}
+}
+
+// candidate is a node paired with its distance to the current query
+type candidate struct {
+	node *hnswNode
+	dist float64
+}
+
+type minHeap []candidate
+
+func (q minHeap) Len() int            { return len(q) }
+func (q minHeap) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q minHeap) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *minHeap) Push(x interface{}) { *q = append(*q, x.(candidate)) }
+func (q *minHeap) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+type maxHeap []candidate
+
+func (q maxHeap) Len() int            { return len(q) }
+func (q maxHeap) Less(i, j int) bool  { return q[i].dist > q[j].dist }
+func (q maxHeap) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *maxHeap) Push(x interface{}) { *q = append(*q, x.(candidate)) }
+func (q *maxHeap) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
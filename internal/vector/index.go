@@ -0,0 +1,126 @@
+package vector
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/azhany/codecli/internal/config"
+)
+
+// Index types selectable through ngt.index_type
+const (
+	IndexTypeFlat = "flat"
+	IndexTypeHNSW = "hnsw"
+)
+
+// Neighbor is a single nearest-neighbour search hit
+type Neighbor struct {
+	ID    uint32
+	Score float64 // Cosine similarity, higher is closer
+}
+
+// VectorIndex is a nearest-neighbour index over chunk vectors.
+//
+// Vectors are owned and persisted by the VectorStore. An index only persists
+// its own structure in Save, and is handed the vectors again on Load.
+type VectorIndex interface {
+	Add(id uint32, vector []float32) error
+	Remove(id uint32) error
+	Search(query []float32, k int) ([]Neighbor, error)
+	Len() int
+	Save(w io.Writer) error
+	Load(r io.Reader, vectors map[uint32][]float32) error
+}
+
+// NewVectorIndex creates an empty index of the type selected in config
+func NewVectorIndex() (VectorIndex, error) {
+	cfg := config.Config.NGT
+	switch cfg.IndexType {
+	case IndexTypeFlat:
+		return NewFlatIndex(), nil
+	case IndexTypeHNSW, "":
+		return NewHNSWIndex(HNSWParams{
+			M:              cfg.EdgeSize,
+			EfConstruction: cfg.EfConstruction,
+			EfSearch:       cfg.EfSearch,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown index type: %s", cfg.IndexType)
+	}
+}
+
+// FlatIndex is an exact index that scans every vector on each query
+type FlatIndex struct {
+	vectors map[uint32][]float32
+	mu      sync.RWMutex
+}
+
+// NewFlatIndex creates an empty brute-force index
+func NewFlatIndex() *FlatIndex {
+	return &FlatIndex{
+		vectors: make(map[uint32][]float32),
+	}
+}
+
+func (f *FlatIndex) Add(id uint32, vector []float32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.vectors[id] = vector
+	return nil
+}
+
+func (f *FlatIndex) Remove(id uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.vectors, id)
+	return nil
+}
+
+func (f *FlatIndex) Search(query []float32, k int) ([]Neighbor, error) {
+	f.mu.RLock()
+	neighbors := make([]Neighbor, 0, len(f.vectors))
+	for id, vector := range f.vectors {
+		neighbors = append(neighbors, Neighbor{ID: id, Score: cosineSimilarity(query, vector)})
+	}
+	f.mu.RUnlock()
+
+	// Sort by score, breaking ties by ID for stable output
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].Score != neighbors[j].Score {
+			return neighbors[i].Score > neighbors[j].Score
+		}
+		return neighbors[i].ID < neighbors[j].ID
+	})
+
+	if k < len(neighbors) {
+		neighbors = neighbors[:k]
+	}
+	return neighbors, nil
+}
+
+func (f *FlatIndex) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return len(f.vectors)
+}
+
+// Save is a no-op: a flat index has no structure beyond its vectors
+func (f *FlatIndex) Save(w io.Writer) error {
+	return nil
+}
+
+func (f *FlatIndex) Load(r io.Reader, vectors map[uint32][]float32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.vectors = make(map[uint32][]float32, len(vectors))
+	for id, vector := range vectors {
+		f.vectors[id] = vector
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+package vector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/azhany/codecli/internal/config"
+	"github.com/azhany/codecli/internal/workspace"
+)
+
+// IndexOptions controls how CreateIndex walks and embeds the workspace
+type IndexOptions struct {
+	Root       string
+	Extensions []string
+	Workers    int  // Concurrent file readers and embedding requests (default: number of CPUs)
+	BatchSize  int  // Chunks per embedding request (default: ngt.batch_size)
+	Rebuild    bool // Discard the existing index instead of updating it
+}
+
+func (o IndexOptions) withDefaults() IndexOptions {
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = config.Config.NGT.BatchSize
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 1
+	}
+	return o
+}
+
+// IndexStats summarizes the changes applied by CreateIndex
+type IndexStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// String formats the stats as a one-line summary
+func (s *IndexStats) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d removed, %d unchanged",
+		s.Added, s.Updated, s.Removed, s.Unchanged)
+}
+
+// scanResult is the outcome of inspecting a single file against the existing index
+type scanResult struct {
+	path     string
+	existing *FileMetadata
+	info     os.FileInfo
+	pending  *pendingFile // nil if the content is unchanged
+	touched  bool         // unchanged content but new stat info
+}
+
+// pendingFile is a new or modified file waiting for its chunk embeddings
+type pendingFile struct {
+	hash    string
+	chunks  []ChunkMetadata
+	vectors [][]float32
+}
+
+// chunkRef addresses a single chunk of a pending file
+type chunkRef struct {
+	file  *pendingFile
+	index int
+}
+
+// CreateIndex creates or incrementally updates the vector index for the codebase.
+// Files whose mtime and content hash match the existing index are left untouched.
+//
+// Files are read and chunked by a pool of opts.Workers goroutines and the
+// resulting chunks are embedded in batches of opts.BatchSize. The store is only
+// modified once every embedding has succeeded, so a cancelled or failed run
+// leaves the previous index intact. IDs are assigned in path order, making
+// them independent of scheduling.
+func (v *VectorStore) CreateIndex(ctx context.Context, opts IndexOptions) (*IndexStats, error) {
+	opts = opts.withDefaults()
+
+	// Start from the existing index, if any
+	if opts.Rebuild {
+		if err := v.reset(); err != nil {
+			return nil, err
+		}
+	} else if err := v.LoadIndex(); err != nil && !errors.Is(err, ErrIndexNotFound) {
+		return nil, fmt.Errorf("failed to load existing index: %w", err)
+	}
+	v.mutex.RLock()
+	closed := v.index == nil
+	v.mutex.RUnlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	files, err := findCodeFiles(opts.Root, opts.Extensions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find code files: %v", err)
+	}
+
+	v.mutex.RLock()
+	existing := make(map[string]*FileMetadata, len(v.metadata))
+	for _, fileMeta := range v.metadata {
+		existing[fileMeta.FilePath] = fileMeta
+	}
+	v.mutex.RUnlock()
+
+	results, err := v.runPipeline(ctx, opts, files, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := v.applyResults(results, existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update vector index: %v", err)
+	}
+
+	// Save metadata to disk
+	if err := v.saveIndex(); err != nil {
+		return nil, fmt.Errorf("failed to save index: %v", err)
+	}
+
+	return stats, nil
+}
+
+// runPipeline scans files and embeds the chunks of new or modified ones
+func (v *VectorStore) runPipeline(parent context.Context, opts IndexOptions, files []string, existing map[string]*FileMetadata) ([]scanResult, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	paths := make(chan string)
+	scanned := make(chan scanResult, opts.Workers)
+	batches := make(chan []chunkRef, opts.Workers)
+
+	go func() {
+		defer close(paths)
+		for _, file := range files {
+			select {
+			case paths <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Stage 1: stat, hash and chunk files
+	var scanWG sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		scanWG.Add(1)
+		go func() {
+			defer scanWG.Done()
+			for path := range paths {
+				result, err := v.scanFile(path, existing[path])
+				if err != nil {
+					fail(fmt.Errorf("failed to process file %s: %v", path, err))
+					return
+				}
+				select {
+				case scanned <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		scanWG.Wait()
+		close(scanned)
+	}()
+
+	// Stage 2: group chunks into fixed-size embedding batches
+	var results []scanResult
+	batcherDone := make(chan struct{})
+	go func() {
+		defer close(batcherDone)
+		defer close(batches)
+
+		var batch []chunkRef
+		send := func() bool {
+			select {
+			case batches <- batch:
+				batch = nil
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for result := range scanned {
+			results = append(results, result)
+			if result.pending == nil {
+				continue
+			}
+			for i := range result.pending.chunks {
+				batch = append(batch, chunkRef{file: result.pending, index: i})
+				if len(batch) == opts.BatchSize && !send() {
+					return
+				}
+			}
+		}
+		if len(batch) > 0 {
+			send()
+		}
+	}()
+
+	// Stage 3: embed batches
+	var embedWG sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		embedWG.Add(1)
+		go func() {
+			defer embedWG.Done()
+			for batch := range batches {
+				texts := make([]string, len(batch))
+				for j, ref := range batch {
+					texts[j] = ref.file.chunks[ref.index].Content
+				}
+
+				embeddings, err := v.llmClient.EmbedBatch(ctx, texts)
+				if err != nil {
+					fail(fmt.Errorf("failed to generate embeddings: %v", err))
+					return
+				}
+
+				for j, ref := range batch {
+					if err := v.validateEmbedding(embeddings[j]); err != nil {
+						fail(err)
+						return
+					}
+					ref.file.vectors[ref.index] = embeddings[j]
+				}
+			}
+		}()
+	}
+
+	embedWG.Wait()
+	<-batcherDone
+	scanWG.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := parent.Err(); err != nil {
+		return nil, fmt.Errorf("indexing cancelled: %v", err)
+	}
+
+	return results, nil
+}
+
+// scanFile compares a file against its existing index entry and, if it is
+// new or modified, reads and chunks it
+func (v *VectorStore) scanFile(path string, old *FileMetadata) (scanResult, error) {
+	result := scanResult{path: path, existing: old}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return result, err
+	}
+	result.info = info
+
+	if old != nil && old.ModTime.Equal(info.ModTime()) && old.Size == info.Size() {
+		return result, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	hash := hashContent(content)
+
+	if old != nil && old.Hash == hash {
+		result.touched = true
+		return result, nil
+	}
+
+	chunks := v.splitIntoChunks(path, string(content))
+	result.pending = &pendingFile{
+		hash:    hash,
+		chunks:  chunks,
+		vectors: make([][]float32, len(chunks)),
+	}
+
+	return result, nil
+}
+
+// applyResults updates the store with the outcome of the pipeline
+func (v *VectorStore) applyResults(results []scanResult, existing map[string]*FileMetadata) (*IndexStats, error) {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].path < results[j].path
+	})
+
+	stats := &IndexStats{}
+	seen := make(map[string]bool, len(results))
+	for _, result := range results {
+		seen[result.path] = true
+
+		switch {
+		case result.pending == nil:
+			if result.touched {
+				// Touched but not modified: refresh the stat info only
+				v.mutex.Lock()
+				result.existing.ModTime = result.info.ModTime()
+				result.existing.Size = result.info.Size()
+				v.mutex.Unlock()
+			}
+			stats.Unchanged++
+			continue
+		case result.existing != nil:
+			if err := v.removeFile(result.existing); err != nil {
+				return nil, err
+			}
+			stats.Updated++
+		default:
+			stats.Added++
+		}
+
+		if err := v.addFile(result); err != nil {
+			return nil, err
+		}
+	}
+
+	// Drop files that no longer exist
+	var removed []string
+	for path := range existing {
+		if !seen[path] {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	for _, path := range removed {
+		if err := v.removeFile(existing[path]); err != nil {
+			return nil, err
+		}
+		stats.Removed++
+	}
+
+	return stats, nil
+}
+
+// addFile stores an embedded file and its chunks, assigning fresh IDs.
+// Files without chunks are still recorded so that they are not reported as
+// new on the next run.
+func (v *VectorStore) addFile(result scanResult) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	pending := result.pending
+	fileMeta := &FileMetadata{
+		ID:       v.nextID,
+		FilePath: result.path,
+		Hash:     pending.hash,
+		ModTime:  result.info.ModTime(),
+		Size:     result.info.Size(),
+		Chunks:   make([]ChunkMetadata, 0, len(pending.chunks)),
+	}
+	v.nextID++
+
+	for i, chunk := range pending.chunks {
+		chunk.ID = v.nextID
+		v.nextID++
+
+		v.vectors[chunk.ID] = &ChunkVector{
+			ChunkMetadata: chunk,
+			Vector:        pending.vectors[i],
+		}
+		v.chunkFiles[chunk.ID] = fileMeta
+		fileMeta.Chunks = append(fileMeta.Chunks, chunk)
+
+		if err := v.index.Add(chunk.ID, pending.vectors[i]); err != nil {
+			return err
+		}
+		v.lexical.Add(chunk.ID, chunk.Content)
+	}
+
+	v.metadata[fileMeta.ID] = fileMeta
+	return nil
+}
+
+// removeFile deletes a file and all of its chunk vectors from the store
+func (v *VectorStore) removeFile(fileMeta *FileMetadata) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	for _, chunk := range fileMeta.Chunks {
+		delete(v.vectors, chunk.ID)
+		delete(v.chunkFiles, chunk.ID)
+		v.lexical.Remove(chunk.ID)
+		if err := v.index.Remove(chunk.ID); err != nil {
+			return err
+		}
+	}
+	delete(v.metadata, fileMeta.ID)
+	return nil
+}
+
+// hashContent returns the hex-encoded SHA-256 of a file's content
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// findCodeFiles finds code files in the workspace
+func findCodeFiles(root string, extensions []string) ([]string, error) {
+	walker := workspace.NewWalker(root)
+	walker.Extensions = extensions
+	return walker.Files()
+}
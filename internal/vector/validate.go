@@ -0,0 +1,59 @@
+package vector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/azhany/codecli/internal/config"
+)
+
+// rebuildHint tells the user how to recover from an incompatible index
+const rebuildHint = "run `codecli index --rebuild` to recreate the index"
+
+// incompatibleIndex builds an ErrIncompatibleIndex error with a rebuild hint
+func incompatibleIndex(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s; %s", ErrIncompatibleIndex, fmt.Sprintf(format, args...), rebuildHint)
+}
+
+// validateHeader checks a stored index against the configured embedding
+// model and dimension
+func validateHeader(header IndexHeader) error {
+	cfg := config.Config
+	if header.Count == 0 {
+		return nil // Nothing embedded yet, any model will do
+	}
+
+	if header.Model != "" && !sameModel(header.Model, cfg.Ollama.EmbeddingModel) {
+		return incompatibleIndex("index was built with embedding model %q (dimension %d) but ollama.embedding_model is %q",
+			header.Model, header.Dimension, cfg.Ollama.EmbeddingModel)
+	}
+	if cfg.NGT.Dimension > 0 && header.Dimension != cfg.NGT.Dimension {
+		return incompatibleIndex("index has dimension %d but ngt.dimension is %d",
+			header.Dimension, cfg.NGT.Dimension)
+	}
+	return nil
+}
+
+// validateEmbedding checks a vector returned by the live embedding model
+// against the configured dimension and the vectors already in the index
+func (v *VectorStore) validateEmbedding(vec []float32) error {
+	cfg := config.Config
+	if cfg.NGT.Dimension > 0 && len(vec) != cfg.NGT.Dimension {
+		return fmt.Errorf("embedding model %q returned %d-dimensional vectors but ngt.dimension is %d; set ngt.dimension to %d and %s",
+			cfg.Ollama.EmbeddingModel, len(vec), cfg.NGT.Dimension, len(vec), rebuildHint)
+	}
+
+	v.mutex.RLock()
+	indexDim := v.header.Dimension
+	v.mutex.RUnlock()
+	if indexDim > 0 && len(vec) != indexDim {
+		return incompatibleIndex("embedding model %q returned %d-dimensional vectors but the index holds %d-dimensional vectors",
+			cfg.Ollama.EmbeddingModel, len(vec), indexDim)
+	}
+	return nil
+}
+
+// sameModel compares Ollama model names, treating a missing tag as ":latest"
+func sameModel(a, b string) bool {
+	return strings.TrimSuffix(a, ":latest") == strings.TrimSuffix(b, ":latest")
+}
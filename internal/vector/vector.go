@@ -1,49 +1,41 @@
 package vector
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 	"sync"
+	"time"
 
+	"github.com/azhany/codecli/internal/chunker"
 	"github.com/azhany/codecli/internal/config"
 	"github.com/azhany/codecli/internal/llm"
 	"github.com/azhany/codecli/internal/types"
 )
 
-// Store handles vector storage and retrieval
-type Store struct {
-	embeddings map[string][]float64
-	metadata   map[string]*FileMetadata
-	mu         sync.RWMutex
-	llmClient  *llm.Client
-}
+// ErrIndexNotFound is returned by LoadIndex when no index has been created yet
+var ErrIndexNotFound = errors.New("index does not exist")
 
-// NewStore creates a new vector store
-func NewStore() (*Store, error) {
-	client, err := llm.NewClient()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create LLM client: %w", err)
-	}
-
-	return &Store{
-		embeddings: make(map[string][]float64),
-		metadata:   make(map[string]*FileMetadata),
-		llmClient:  client,
-	}, nil
-}
+// ErrClosed is returned by a VectorStore used after Close without loading
+// an index again
+var ErrClosed = errors.New("vector store is closed")
 
 // FileMetadata represents metadata for indexed files
 type FileMetadata struct {
 	ID       uint32
 	FilePath string
-	Content  string
+	Hash     string
+	ModTime  time.Time
+	Size     int64
 	Chunks   []ChunkMetadata
 }
 
@@ -53,6 +45,8 @@ type ChunkMetadata struct {
 	StartLine int
 	EndLine   int
 	Content   string
+	Symbol    string // Declaration the chunk belongs to, if known
+	Kind      string // Declaration kind, see the chunker package
 }
 
 // ChunkVector represents a chunk with its embedding vector
@@ -66,8 +60,16 @@ type VectorStore struct {
 	llmClient *llm.Client
 	metadata  map[uint32]*FileMetadata
 	vectors   map[uint32]*ChunkVector // Map of chunk ID to vector
-	mutex     sync.RWMutex
-	nextID    uint32
+	index     VectorIndex
+	lexical   *LexicalIndex // BM25 index over chunk text
+	// chunkFiles maps chunk IDs back to the file they belong to
+	chunkFiles map[uint32]*FileMetadata
+	// header describes the index loaded from disk
+	header IndexHeader
+	// release unmaps the vectors file loaded by LoadIndex, if any
+	release func() error
+	mutex   sync.RWMutex
+	nextID  uint32
 }
 
 // NewVectorStore creates a new vector store
@@ -78,11 +80,19 @@ func NewVectorStore() (*VectorStore, error) {
 		return nil, fmt.Errorf("failed to initialize LLM client: %v", err)
 	}
 
+	index, err := NewVectorIndex()
+	if err != nil {
+		return nil, err
+	}
+
 	store := &VectorStore{
-		llmClient: llmClient,
-		metadata:  make(map[uint32]*FileMetadata),
-		vectors:   make(map[uint32]*ChunkVector),
-		nextID:    1,
+		llmClient:  llmClient,
+		metadata:   make(map[uint32]*FileMetadata),
+		vectors:    make(map[uint32]*ChunkVector),
+		index:      index,
+		lexical:    NewLexicalIndex(),
+		chunkFiles: make(map[uint32]*FileMetadata),
+		nextID:     1,
 	}
 
 	// Create index directory if it doesn't exist
@@ -117,28 +127,6 @@ func cosineSimilarity(a, b []float32) float64 {
 	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
 }
 
-// CreateIndex creates a new vector index for the codebase
-func (v *VectorStore) CreateIndex(root string, extensions []string) error {
-	// Process files
-	files, err := findCodeFiles(root, extensions)
-	if err != nil {
-		return fmt.Errorf("failed to find code files: %v", err)
-	}
-
-	for _, file := range files {
-		if err := v.processFile(file); err != nil {
-			return fmt.Errorf("failed to process file %s: %v", file, err)
-		}
-	}
-
-	// Save metadata to disk
-	if err := v.saveIndex(); err != nil {
-		return fmt.Errorf("failed to save index: %v", err)
-	}
-
-	return nil
-}
-
 // Search performs a semantic search on the codebase
 func (v *VectorStore) Search(query string, limit int) ([]types.SearchResult, error) {
 	// Generate embedding for query
@@ -147,95 +135,207 @@ func (v *VectorStore) Search(query string, limit int) ([]types.SearchResult, err
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %v", err)
 	}
+	if err := v.validateEmbedding(queryEmbedding); err != nil {
+		return nil, err
+	}
 
-	type scoreEntry struct {
-		chunkVec *ChunkVector
-		fileMeta *FileMetadata
-		score    float64
+	// The index may hold vectors mapped from disk, which LoadIndex and Close
+	// unmap once no reader holds the lock
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	if v.index == nil {
+		return nil, ErrClosed
 	}
 
-	// Calculate cosine similarity for all vectors
-	var scores []scoreEntry
+	neighbors, err := v.index.Search(queryEmbedding, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search index: %v", err)
+	}
 
-	v.mutex.RLock()
-	for _, fileMeta := range v.metadata {
-		for _, chunk := range fileMeta.Chunks {
-			if vec, ok := v.vectors[chunk.ID]; ok {
-				score := cosineSimilarity(queryEmbedding, vec.Vector)
-				scores = append(scores, scoreEntry{
-					chunkVec: vec,
-					fileMeta: fileMeta,
-					score:    score,
-				})
-			}
+	searchResults := make([]types.SearchResult, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		if result, ok := v.chunkResult(neighbor.ID); ok {
+			result.Distance = neighbor.Score
+			result.SemanticScore = neighbor.Score
+			searchResults = append(searchResults, result)
 		}
 	}
-	v.mutex.RUnlock()
 
-	// Sort by score (higher is better for cosine similarity)
-	sort.Slice(scores, func(i, j int) bool {
-		return scores[i].score > scores[j].score
-	})
-
-	// Take top K results
-	if limit > len(scores) {
-		limit = len(scores)
-	}
-
-	// Convert to SearchResult format
-	searchResults := make([]types.SearchResult, 0, limit)
-	for i := 0; i < limit; i++ {
-		result := scores[i]
-		searchResults = append(searchResults, types.SearchResult{
-			Path:     result.fileMeta.FilePath,
-			Line:     result.chunkVec.StartLine,
-			Content:  result.chunkVec.Content,
-			Distance: result.score,
-		})
+	return searchResults, nil
+}
+
+// SearchLexical ranks chunks by BM25 score against the query terms. Unlike
+// Search it needs no embedding model.
+func (v *VectorStore) SearchLexical(query string, limit int) ([]types.SearchResult, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	if v.index == nil {
+		return nil, ErrClosed
+	}
+
+	neighbors := v.lexical.Search(query, limit)
+	searchResults := make([]types.SearchResult, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		if result, ok := v.chunkResult(neighbor.ID); ok {
+			result.LexicalScore = neighbor.Score
+			searchResults = append(searchResults, result)
+		}
 	}
 
 	return searchResults, nil
 }
 
-// saveIndex saves metadata and vectors to disk
+// chunkResult describes a chunk as a search result. The caller must hold
+// the mutex.
+func (v *VectorStore) chunkResult(id uint32) (types.SearchResult, bool) {
+	chunkVec, ok := v.vectors[id]
+	if !ok {
+		return types.SearchResult{}, false
+	}
+	fileMeta, ok := v.chunkFiles[id]
+	if !ok {
+		return types.SearchResult{}, false
+	}
+	return types.SearchResult{
+		Path:    fileMeta.FilePath,
+		Line:    chunkVec.StartLine,
+		Content: chunkVec.Content,
+		Symbol:  chunkVec.Symbol,
+		Kind:    chunkVec.Kind,
+	}, true
+}
+
+// saveIndex writes the metadata, packed vectors and index structure to disk
 func (v *VectorStore) saveIndex() error {
 	indexPath := config.Config.NGT.IndexPath
+	encoding, err := ParseVectorEncoding(config.Config.NGT.Quantization)
+	if err != nil {
+		return err
+	}
 
 	v.mutex.RLock()
-	data := struct {
-		Metadata map[uint32]*FileMetadata `json:"metadata"`
-		Vectors  map[uint32]*ChunkVector  `json:"vectors"`
-	}{
-		Metadata: v.metadata,
-		Vectors:  v.vectors,
+	files := make([]*FileMetadata, 0, len(v.metadata))
+	for _, fileMeta := range v.metadata {
+		files = append(files, fileMeta)
 	}
-	metadataBytes, err := json.Marshal(data)
-	v.mutex.RUnlock()
+	ids := make([]uint32, 0, len(v.vectors))
+	for id := range v.vectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	vectors := make([][]float32, len(ids))
+	for i, id := range ids {
+		vectors[i] = v.vectors[id].Vector
+	}
+	header := IndexHeader{
+		Version:  FormatVersion,
+		Encoding: encoding,
+		Model:    config.Config.Ollama.EmbeddingModel,
+	}
+	if len(vectors) > 0 {
+		header.Dimension = len(vectors[0])
+	}
+	header.Count = len(vectors)
 
+	var graph, lexical bytes.Buffer
+	err = v.index.Save(&graph)
+	if err == nil {
+		err = v.lexical.Save(&lexical)
+	}
+	v.mutex.RUnlock()
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %v", err)
+		return fmt.Errorf("failed to save vector index: %v", err)
 	}
 
-	metadataPath := filepath.Join(indexPath, "metadata.json")
-	if err := ioutil.WriteFile(metadataPath, metadataBytes, 0644); err != nil {
+	if err := writeVectorFile(filepath.Join(indexPath, vectorsFile), header, ids, vectors); err != nil {
+		return fmt.Errorf("failed to write vectors file: %v", err)
+	}
+	if err := writeFileAtomic(v.graphPath(), func(w io.Writer) error {
+		_, err := w.Write(graph.Bytes())
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to write vector index file: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(indexPath, lexicalFile), func(w io.Writer) error {
+		_, err := w.Write(lexical.Bytes())
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to write lexical index file: %v", err)
+	}
+	// Metadata goes last: its presence marks a complete index
+	if err := writeMetadataFile(filepath.Join(indexPath, metadataFile), files); err != nil {
 		return fmt.Errorf("failed to write metadata file: %v", err)
 	}
 
+	// The legacy file has been superseded by the migrated index
+	if err := os.Remove(filepath.Join(indexPath, legacyMetadataFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove legacy metadata file: %v", err)
+	}
+
 	return nil
 }
 
-// LoadIndex loads metadata and vectors from disk
+// graphPath returns the file holding the structure of the configured index type
+func (v *VectorStore) graphPath() string {
+	indexType := config.Config.NGT.IndexType
+	if indexType == "" {
+		indexType = IndexTypeHNSW
+	}
+	return filepath.Join(config.Config.NGT.IndexPath, "graph."+indexType)
+}
+
+// LoadIndex loads metadata and vectors from disk. A legacy metadata.json
+// index is migrated to the current format.
 func (v *VectorStore) LoadIndex() error {
 	indexPath := config.Config.NGT.IndexPath
-	metadataPath := filepath.Join(indexPath, "metadata.json")
+	metadataPath := filepath.Join(indexPath, metadataFile)
 
 	// Check if metadata exists
 	if _, err := os.Stat(metadataPath); os.IsNotExist(err) {
-		return fmt.Errorf("index does not exist at path: %s", metadataPath)
+		legacyPath := filepath.Join(indexPath, legacyMetadataFile)
+		if _, err := os.Stat(legacyPath); err == nil {
+			return v.migrateLegacyIndex(legacyPath)
+		}
+		return fmt.Errorf("%w at path: %s", ErrIndexNotFound, indexPath)
+	}
+
+	meta, err := readMetadataFile(metadataPath)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata file: %v", err)
+	}
+	if meta.Version != FormatVersion {
+		return incompatibleIndex("index has format version %d, this build reads version %d",
+			meta.Version, FormatVersion)
 	}
 
-	// Load metadata and vectors
-	metadataBytes, err := ioutil.ReadFile(metadataPath)
+	vf, err := readVectorFile(filepath.Join(indexPath, vectorsFile))
+	if err != nil {
+		return fmt.Errorf("failed to read vectors file: %v", err)
+	}
+
+	if err := validateHeader(vf.header); err != nil {
+		vf.release()
+		return err
+	}
+
+	vectors := make(map[uint32][]float32, len(vf.ids))
+	for i, id := range vf.ids {
+		vectors[id] = vf.vectors[i]
+	}
+
+	if err := v.setIndexData(vf.header, meta.Files, vectors, vf.release); err != nil {
+		vf.release()
+		return err
+	}
+	return nil
+}
+
+// migrateLegacyIndex loads a version 0 metadata.json index and rewrites it
+// in the current format
+func (v *VectorStore) migrateLegacyIndex(path string) error {
+	metadataBytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to read metadata file: %v", err)
 	}
@@ -244,18 +344,54 @@ func (v *VectorStore) LoadIndex() error {
 		Metadata map[uint32]*FileMetadata `json:"metadata"`
 		Vectors  map[uint32]*ChunkVector  `json:"vectors"`
 	}
-
 	if err := json.Unmarshal(metadataBytes, &data); err != nil {
 		return fmt.Errorf("failed to unmarshal data: %v", err)
 	}
 
-	v.mutex.Lock()
-	v.metadata = data.Metadata
-	v.vectors = data.Vectors
+	files := make([]*FileMetadata, 0, len(data.Metadata))
+	for _, fileMeta := range data.Metadata {
+		files = append(files, fileMeta)
+	}
+	vectors := make(map[uint32][]float32, len(data.Vectors))
+	for id, vec := range data.Vectors {
+		vectors[id] = vec.Vector
+	}
+
+	// The legacy format did not record the model, so assume the configured one
+	header := IndexHeader{
+		Model: config.Config.Ollama.EmbeddingModel,
+		Count: len(vectors),
+	}
+	for _, vec := range vectors {
+		header.Dimension = len(vec)
+		break
+	}
+	if err := validateHeader(header); err != nil {
+		return err
+	}
+
+	if err := v.setIndexData(header, files, vectors, nil); err != nil {
+		return err
+	}
+	if err := v.saveIndex(); err != nil {
+		return fmt.Errorf("failed to migrate legacy index: %v", err)
+	}
+	return nil
+}
+
+// setIndexData replaces the in-memory index with loaded files and vectors.
+// release frees the storage backing vectors once the store no longer uses it.
+func (v *VectorStore) setIndexData(header IndexHeader, files []*FileMetadata, vectors map[uint32][]float32, release func() error) error {
+	metadata := make(map[uint32]*FileMetadata, len(files))
+	chunkVectors := make(map[uint32]*ChunkVector, len(vectors))
+	chunkFiles := make(map[uint32]*FileMetadata, len(vectors))
+	indexed := make(map[uint32][]float32, len(vectors))
+	texts := make(map[uint32]string, len(vectors))
 
 	// Find the highest ID to set nextID
 	maxID := uint32(0)
-	for _, fileMeta := range v.metadata {
+	for _, fileMeta := range files {
+		metadata[fileMeta.ID] = fileMeta
 		if fileMeta.ID > maxID {
 			maxID = fileMeta.ID
 		}
@@ -263,146 +399,150 @@ func (v *VectorStore) LoadIndex() error {
 			if chunk.ID > maxID {
 				maxID = chunk.ID
 			}
+			if vec, ok := vectors[chunk.ID]; ok {
+				chunkVectors[chunk.ID] = &ChunkVector{ChunkMetadata: chunk, Vector: vec}
+				chunkFiles[chunk.ID] = fileMeta
+				indexed[chunk.ID] = vec
+				texts[chunk.ID] = chunk.Content
+			}
 		}
 	}
-	v.nextID = maxID + 1
-	v.mutex.Unlock()
-
-	return nil
-}
-
-// Close cleans up resources
-func (v *VectorStore) Close() error {
-	return nil
-}
-
-// FormatSearchResult formats a search result for display
-func FormatSearchResult(sr types.SearchResult) string {
-	return fmt.Sprintf("File: %s (line %d, score: %.4f)\n%s",
-		sr.Path, sr.Line, sr.Distance, sr.Content)
-}
-
-// findCodeFiles finds code files in the workspace
-func findCodeFiles(root string, extensions []string) ([]string, error) {
-	var files []string
 
-	// Walk directory and filter files
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	// Restore the index structure, rebuilding it from the vectors if it is
+	// missing (e.g. after switching ngt.index_type)
+	index, err := NewVectorIndex()
+	if err != nil {
+		return err
+	}
+	graph, err := os.Open(v.graphPath())
+	switch {
+	case err == nil:
+		err = index.Load(bufio.NewReader(graph), indexed)
+		graph.Close()
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to load vector index: %v", err)
 		}
-
-		if !info.IsDir() {
-			for _, ext := range extensions {
-				if filepath.Ext(path) == ext {
-					files = append(files, path)
-					break
-				}
+	case os.IsNotExist(err):
+		ids := make([]uint32, 0, len(indexed))
+		for id := range indexed {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			if err := index.Add(id, indexed[id]); err != nil {
+				return fmt.Errorf("failed to rebuild vector index: %v", err)
 			}
 		}
-
-		return nil
-	})
-
-	return files, err
-}
-
-// processFile processes a single file and adds its vectors to the store
-func (v *VectorStore) processFile(file string) error {
-	// Read file content
-	content, err := ioutil.ReadFile(file)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %v", err)
+	default:
+		return fmt.Errorf("failed to open vector index: %v", err)
 	}
 
-	// Split content into chunks
-	chunks := v.splitIntoChunks(string(content))
-	if len(chunks) == 0 {
-		return nil // Skip empty files
+	// Likewise for the lexical index, which indexes created before it
+	// existed do not have
+	lexical := NewLexicalIndex()
+	lexicalData, err := os.Open(filepath.Join(config.Config.NGT.IndexPath, lexicalFile))
+	switch {
+	case err == nil:
+		err = lexical.Load(bufio.NewReader(lexicalData), texts)
+		lexicalData.Close()
+		if err != nil {
+			return fmt.Errorf("failed to load lexical index: %v", err)
+		}
+	case os.IsNotExist(err):
+		for id, text := range texts {
+			lexical.Add(id, text)
+		}
+	default:
+		return fmt.Errorf("failed to open lexical index: %v", err)
 	}
 
 	v.mutex.Lock()
-	fileID := v.nextID
-	v.nextID++
+	previous := v.release
+	v.header = header
+	v.metadata = metadata
+	v.vectors = chunkVectors
+	v.chunkFiles = chunkFiles
+	v.index = index
+	v.lexical = lexical
+	v.release = release
+	v.nextID = maxID + 1
 	v.mutex.Unlock()
 
-	// Create file metadata
-	fileMeta := &FileMetadata{
-		ID:       fileID,
-		FilePath: file,
-		Content:  string(content),
-		Chunks:   make([]ChunkMetadata, 0, len(chunks)),
+	if previous != nil {
+		return previous()
 	}
+	return nil
+}
 
-	// Process each chunk
-	for _, chunk := range chunks {
-		if strings.TrimSpace(chunk.Content) == "" {
-			continue // Skip empty chunks
-		}
-
-		// Generate embedding for chunk
-		ctx := context.Background()
-		embedding, err := v.llmClient.EmbedText(ctx, chunk.Content)
-		if err != nil {
-			return fmt.Errorf("failed to generate embedding for chunk: %v", err)
-		}
-
-		v.mutex.Lock()
-		chunkID := v.nextID
-		v.nextID++
-
-		// Store chunk vector
-		chunkVec := &ChunkVector{
-			ChunkMetadata: chunk,
-			Vector:        embedding,
-		}
-		chunkVec.ID = chunkID
-		v.vectors[chunkID] = chunkVec
-
-		// Add chunk metadata
-		fileMeta.Chunks = append(fileMeta.Chunks, chunk)
-		v.mutex.Unlock()
+// reset discards the in-memory index so it can be rebuilt from scratch
+func (v *VectorStore) reset() error {
+	index, err := NewVectorIndex()
+	if err != nil {
+		return err
 	}
 
-	// Store file metadata
 	v.mutex.Lock()
-	v.metadata[fileID] = fileMeta
+	previous := v.release
+	v.header = IndexHeader{}
+	v.metadata = make(map[uint32]*FileMetadata)
+	v.vectors = make(map[uint32]*ChunkVector)
+	v.chunkFiles = make(map[uint32]*FileMetadata)
+	v.index = index
+	v.lexical = NewLexicalIndex()
+	v.release = nil
+	v.nextID = 1
 	v.mutex.Unlock()
 
+	if previous != nil {
+		return previous()
+	}
 	return nil
 }
 
-// splitIntoChunks splits file content into manageable chunks
-func (v *VectorStore) splitIntoChunks(content string) []ChunkMetadata {
-	lines := strings.Split(content, "\n")
-	chunks := make([]ChunkMetadata, 0)
-
-	const maxLinesPerChunk = 50 // Configurable chunk size
-	const overlapLines = 5      // Lines to overlap between chunks
-
-	for i := 0; i < len(lines); i += maxLinesPerChunk - overlapLines {
-		endIdx := i + maxLinesPerChunk
-		if endIdx > len(lines) {
-			endIdx = len(lines)
-		}
-
-		chunkLines := lines[i:endIdx]
-		chunkContent := strings.Join(chunkLines, "\n")
+// Close cleans up resources. The index and vectors are dropped along with
+// the memory they may be mapped from; searching afterwards returns
+// ErrClosed until an index is loaded again.
+func (v *VectorStore) Close() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 
-		if strings.TrimSpace(chunkContent) != "" {
-			chunk := ChunkMetadata{
-				StartLine: i + 1, // 1-based line numbering
-				EndLine:   endIdx,
-				Content:   chunkContent,
-			}
-			chunks = append(chunks, chunk)
-		}
+	v.index = nil
+	v.vectors = nil
+	if v.release == nil {
+		return nil
+	}
+	err := v.release()
+	v.release = nil
+	return err
+}
 
-		// Break if we've reached the end
-		if endIdx >= len(lines) {
-			break
-		}
+// FormatSearchResult formats a search result for display
+func FormatSearchResult(sr types.SearchResult) string {
+	if sr.Score != 0 {
+		return fmt.Sprintf("File: %s (line %d, score: %.4f, semantic: %.4f, lexical: %.4f)\n%s",
+			sr.Path, sr.Line, sr.Score, sr.SemanticScore, sr.LexicalScore, sr.Content)
 	}
+	if sr.Symbol != "" {
+		return fmt.Sprintf("File: %s (line %d, %s %s, score: %.4f)\n%s",
+			sr.Path, sr.Line, sr.Kind, sr.Symbol, sr.Distance, sr.Content)
+	}
+	return fmt.Sprintf("File: %s (line %d, score: %.4f)\n%s",
+		sr.Path, sr.Line, sr.Distance, sr.Content)
+}
 
+// splitIntoChunks splits file content into chunks along the declarations
+// of the file's language
+func (v *VectorStore) splitIntoChunks(path string, content string) []ChunkMetadata {
+	parts := chunker.ForFile(path).Chunk(content)
+	chunks := make([]ChunkMetadata, 0, len(parts))
+	for _, part := range parts {
+		chunks = append(chunks, ChunkMetadata{
+			StartLine: part.StartLine,
+			EndLine:   part.EndLine,
+			Content:   part.Content,
+			Symbol:    part.Symbol,
+			Kind:      part.Kind,
+		})
+	}
 	return chunks
 }
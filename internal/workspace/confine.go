@@ -0,0 +1,250 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/azhany/codecli/internal/config"
+)
+
+var (
+	// ErrOutsideWorkspace is returned when a path resolves outside the workspace
+	ErrOutsideWorkspace = errors.New("path is outside the workspace")
+	// ErrProtected is returned for writes to protected paths
+	ErrProtected = errors.New("path is protected")
+)
+
+// Confinement keeps file access inside the workspace root and any extra
+// allowed roots, and keeps writes away from protected paths
+type Confinement struct {
+	roots          []string   // Real paths; the workspace root comes first
+	protected      []*Pattern // Relative to the workspace root
+	protectedPaths []string   // Real paths of the index and config files
+}
+
+// NewConfinement creates a confinement from the workspace settings. Besides
+// workspace.protected_paths, the index directory and the configuration files
+// in use are protected.
+func NewConfinement() (*Confinement, error) {
+	cfg := config.Config.Workspace
+	c := &Confinement{}
+
+	for _, root := range append([]string{cfg.Root}, cfg.AllowedRoots...) {
+		real, err := realPath(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %v", root, err)
+		}
+		c.roots = append(c.roots, real)
+	}
+
+	for _, pattern := range cfg.ProtectedPaths {
+		p, err := CompilePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace.protected_paths entry: %v", err)
+		}
+		c.protected = append(c.protected, p)
+	}
+
+	for _, path := range append([]string{config.Config.NGT.IndexPath}, config.FilesUsed()...) {
+		if path == "" {
+			continue
+		}
+		real, err := realPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %v", path, err)
+		}
+		c.protectedPaths = append(c.protectedPaths, real)
+	}
+	return c, nil
+}
+
+// Root returns the real path of the workspace root
+func (c *Confinement) Root() string {
+	return c.roots[0]
+}
+
+// Resolve returns the real path of path, which is taken relative to the
+// workspace root unless absolute, and checks that it lies inside one of
+// the allowed roots
+func (c *Confinement) Resolve(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.roots[0], path)
+	}
+	resolved, err := realPath(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", path, err)
+	}
+
+	for _, root := range c.roots {
+		if Within(root, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrOutsideWorkspace, path)
+}
+
+// ResolveWrite is like Resolve, but also refuses protected paths and
+// anything inside them
+func (c *Confinement) ResolveWrite(path string) (string, error) {
+	resolved, err := c.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, protected := range c.protectedPaths {
+		if Within(protected, resolved) {
+			return "", fmt.Errorf("%w: %s", ErrProtected, path)
+		}
+	}
+
+	if !Within(c.roots[0], resolved) {
+		return resolved, nil
+	}
+	rel, err := filepath.Rel(c.roots[0], resolved)
+	if err != nil || rel == "." {
+		return resolved, nil
+	}
+	// Check each ancestor too, so that ".git" also protects ".git/config"
+	rel = filepath.ToSlash(rel)
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' {
+			if p := c.protectedPattern(rel[:i], true); p != nil {
+				return "", fmt.Errorf("%w: %s is inside %q", ErrProtected, path, p.String())
+			}
+		}
+	}
+	if p := c.protectedPattern(rel, isExistingDir(resolved)); p != nil {
+		return "", fmt.Errorf("%w: %s matches %q", ErrProtected, path, p.String())
+	}
+	return resolved, nil
+}
+
+func (c *Confinement) protectedPattern(rel string, isDir bool) *Pattern {
+	for _, p := range c.protected {
+		if p.Match(rel, isDir) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Rel returns path relative to the workspace root for display, or path
+// itself if it lies elsewhere
+func (c *Confinement) Rel(path string) string {
+	if !Within(c.roots[0], path) {
+		return path
+	}
+	rel, err := filepath.Rel(c.roots[0], path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+func isExistingDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Resolve returns the absolute, symlink-free form of path, which is taken
+// relative to root unless absolute, and checks that it lies inside root. The
+// path need not exist yet; symlinks in its existing ancestors are followed,
+// so a link pointing out of the workspace is caught.
+func Resolve(root, path string) (string, error) {
+	realRoot, err := realPath(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace root: %v", err)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	resolved, err := realPath(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", path, err)
+	}
+
+	if !Within(realRoot, resolved) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideWorkspace, path)
+	}
+	return resolved, nil
+}
+
+// Within reports whether path is dir or below it. Both must be clean
+// absolute paths.
+func Within(dir, path string) bool {
+	if path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// maxLinks bounds the dangling symlinks followed while resolving a path
+const maxLinks = 40
+
+// realPath makes path absolute and resolves symlinks in its longest existing
+// prefix, appending the components that do not exist yet. A dangling
+// symlink is followed to where it points, since writing through it would
+// create its target.
+func realPath(path string) (string, error) {
+	return resolveLinks(path, 0)
+}
+
+func resolveLinks(path string, links int) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	current := abs
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return join(resolved, missing), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if info, err := os.Lstat(current); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if links >= maxLinks {
+				return "", fmt.Errorf("too many levels of symbolic links: %s", abs)
+			}
+			target, err := os.Readlink(current)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				// Relative to the link's real directory, which exists
+				dir, err := filepath.EvalSymlinks(filepath.Dir(current))
+				if err != nil {
+					return "", err
+				}
+				target = filepath.Join(dir, target)
+			}
+			return resolveLinks(join(target, missing), links+1)
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return abs, nil
+		}
+		missing = append(missing, filepath.Base(current))
+		current = parent
+	}
+}
+
+// join appends the components collected in reverse order by realPath
+func join(path string, missing []string) string {
+	for i := len(missing) - 1; i >= 0; i-- {
+		path = filepath.Join(path, missing[i])
+	}
+	return path
+}
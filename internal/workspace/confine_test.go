@@ -0,0 +1,62 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	root, _ = filepath.EvalSymlinks(root)
+	outside, _ = filepath.EvalSymlinks(outside)
+
+	mustLink := func(target, name string) {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mustLink(outside, "out")
+	mustLink(filepath.Join(outside, "new.txt"), "dangling")
+	mustLink("../"+filepath.Base(outside)+"/x", "dangling-relative")
+	mustLink("dir/missing.txt", "dangling-inside")
+	mustLink("dangling", "chain")
+	mustLink("loop-b", "loop-a")
+	mustLink("loop-a", "loop-b")
+
+	tests := []struct {
+		path string
+		want string // "" if outside the workspace
+	}{
+		{"a.txt", filepath.Join(root, "a.txt")},
+		{"dir/new/deeper.txt", filepath.Join(root, "dir/new/deeper.txt")},
+		{"dir/../a.txt", filepath.Join(root, "a.txt")},
+		{"dangling-inside", filepath.Join(root, "dir/missing.txt")},
+		{"../x", ""},
+		{"out/x", ""},
+		{"dangling", ""},
+		{"dangling-relative", ""},
+		{"chain", ""},
+		{filepath.Join(outside, "x"), ""},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(root, tt.path)
+		switch {
+		case tt.want == "":
+			if !errors.Is(err, ErrOutsideWorkspace) {
+				t.Errorf("Resolve(%q) = %q, %v; want ErrOutsideWorkspace", tt.path, got, err)
+			}
+		case err != nil || got != tt.want:
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+
+	if _, err := Resolve(root, "loop-a"); err == nil {
+		t.Error("Resolve of a symlink loop succeeded")
+	}
+}
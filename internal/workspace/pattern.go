@@ -0,0 +1,125 @@
+package workspace
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a compiled gitignore-style glob
+type Pattern struct {
+	source  string
+	re      *regexp.Regexp
+	negate  bool // "!pattern" re-includes a previously excluded path
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// CompilePattern compiles a gitignore-style glob.
+//
+// A pattern without a slash matches a file or directory name at any depth;
+// a pattern containing a slash is anchored to the directory it was defined
+// in. "*" and "?" never match "/", while "**" matches across directories
+// ("**/x", "x/**" and "a/**/b"); elsewhere "**" is the same as "*".
+func CompilePattern(pattern string) (*Pattern, error) {
+	p := &Pattern{source: pattern}
+
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern: %q", p.source)
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	expr, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", p.source, err)
+	}
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", p.source, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// Match reports whether a slash-separated path relative to the pattern's
+// base directory matches
+func (p *Pattern) Match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(rel)
+}
+
+// String returns the pattern as written
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// globToRegexp translates glob syntax into an unanchored regular expression
+func globToRegexp(glob string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				atStart := i == 0 || glob[i-1] == '/'
+				atEnd := i+2 == len(glob) || glob[i+2] == '/'
+				switch {
+				case !atStart || !atEnd:
+					// Like git, "**" inside a segment, as in "a**b", is
+					// just a "*"
+					b.WriteString("[^/]*")
+					i++
+				case i+2 == len(glob):
+					// Trailing "/**" matches everything inside
+					b.WriteString(".*")
+					i++
+				default:
+					// "**/" matches zero or more directories
+					b.WriteString("(?:.*/)?")
+					i += 2
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			if class == "" || class == "^" {
+				return "", fmt.Errorf("empty character class")
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String(), nil
+}
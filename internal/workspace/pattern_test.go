@@ -0,0 +1,37 @@
+package workspace
+
+import "testing"
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "dir/a.log", false, true},
+		{"/*.log", "dir/a.log", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"**/x", "a/b/x", false, true},
+		{"x/**", "x/a/b", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a**b", "axxb", false, true},
+		{"a**b", "a/b", false, false},
+		{"foo/**bar", "foo/xbar", false, true},
+		{"foo/**bar", "foo/x/bar", false, false},
+		{"**.tmp", "dir/a.tmp", false, true},
+	}
+	for _, tt := range tests {
+		p, err := CompilePattern(tt.pattern)
+		if err != nil {
+			t.Errorf("CompilePattern(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := p.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q.Match(%q, %v) = %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
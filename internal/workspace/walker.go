@@ -0,0 +1,287 @@
+// Package workspace walks the project tree, applying the configured exclude
+// patterns, ignore files and file size limits
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/azhany/codecli/internal/config"
+)
+
+// DefaultIgnoreFiles are read in every directory while walking
+var DefaultIgnoreFiles = []string{".gitignore", ".codecliignore"}
+
+// binarySniffLen is how much of a file is inspected for NUL bytes,
+// matching git's heuristic
+const binarySniffLen = 8000
+
+// Walker walks a workspace tree
+type Walker struct {
+	Root            string
+	ExcludePatterns []string // Gitignore-style globs relative to Root
+	Extensions      []string // Only report files with these extensions; empty reports all
+	IgnoreFiles     []string // Per-directory ignore files to honour
+	MaxFileSize     int64    // Skip larger files; 0 disables the limit
+	SkipBinary      bool     // Skip files that look binary
+
+	// Warn reports problems that do not stop the walk, such as a directory
+	// that cannot be read and is skipped; nil ignores them
+	Warn func(err error)
+}
+
+// NewWalker creates a walker for root configured from the workspace settings
+func NewWalker(root string) *Walker {
+	cfg := config.Config.Workspace
+	if root == "" {
+		root = cfg.Root
+	}
+	return &Walker{
+		Root:            root,
+		ExcludePatterns: cfg.ExcludePatterns,
+		IgnoreFiles:     DefaultIgnoreFiles,
+		MaxFileSize:     cfg.MaxFileSize,
+		SkipBinary:      true,
+		Warn: func(err error) {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		},
+	}
+}
+
+// WalkFunc is called for every file and directory that is not ignored.
+// Returning filepath.SkipDir from a directory skips its contents.
+type WalkFunc func(path string, info os.FileInfo) error
+
+// ruleSet is the compiled patterns of one ignore source, relative to base
+type ruleSet struct {
+	base     string // Slash-separated directory relative to the root, "" for the root
+	patterns []*Pattern
+}
+
+// Walk calls fn for each non-ignored entry below the root in lexical order.
+// Symbolic links are followed, but a directory already visited through
+// another path is not entered again, so link loops terminate.
+func (w *Walker) Walk(fn WalkFunc) error {
+	rootRules, err := compileRules("", w.ExcludePatterns)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(w.Root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fn(w.Root, info)
+	}
+
+	visited := make(map[string]bool)
+	return w.walkDir(w.Root, "", []ruleSet{rootRules}, visited, fn)
+}
+
+func (w *Walker) walkDir(dir, rel string, rules []ruleSet, visited map[string]bool, fn WalkFunc) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(real); err == nil {
+		real = abs
+	}
+	if visited[real] {
+		return nil
+	}
+	visited[real] = true
+
+	// An unreadable directory below the root is skipped rather than
+	// ending the walk
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if rel == "" {
+			return err
+		}
+		w.warn(fmt.Errorf("skipping unreadable directory: %v", err))
+		return nil
+	}
+
+	for _, name := range w.IgnoreFiles {
+		set, err := readIgnoreFile(filepath.Join(dir, name), rel)
+		if err != nil {
+			if rel == "" {
+				return err
+			}
+			w.warn(fmt.Errorf("skipping %s: %v", dir, err))
+			return nil
+		}
+		if set != nil {
+			rules = append(rules[:len(rules):len(rules)], *set)
+		}
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		childPath := filepath.Join(dir, name)
+		childRel := path.Join(rel, name)
+
+		// Stat follows symlinks; dangling links are skipped
+		info, err := os.Stat(childPath)
+		if err != nil {
+			if entry.Type()&os.ModeSymlink != 0 {
+				continue
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			if name == ".git" || ignored(rules, childRel, true) {
+				continue
+			}
+			if err := fn(childPath, info); err != nil {
+				if err == filepath.SkipDir {
+					continue
+				}
+				return err
+			}
+			if err := w.walkDir(childPath, childRel, rules, visited, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !info.Mode().IsRegular() || ignored(rules, childRel, false) || !w.hasExtension(name) {
+			continue
+		}
+		if w.MaxFileSize > 0 && info.Size() > w.MaxFileSize {
+			continue
+		}
+		if w.SkipBinary {
+			binary, err := isBinary(childPath)
+			if err != nil {
+				return err
+			}
+			if binary {
+				continue
+			}
+		}
+
+		if err := fn(childPath, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Walker) warn(err error) {
+	if w.Warn != nil {
+		w.Warn(err)
+	}
+}
+
+// Files returns the paths of all non-ignored files
+func (w *Walker) Files() ([]string, error) {
+	var files []string
+	err := w.Walk(func(path string, info os.FileInfo) error {
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (w *Walker) hasExtension(name string) bool {
+	if len(w.Extensions) == 0 {
+		return true
+	}
+	ext := filepath.Ext(name)
+	for _, e := range w.Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored evaluates rule sets from the root downwards; the last matching
+// pattern decides, so a nested "!pattern" can re-include a file
+func ignored(rules []ruleSet, rel string, isDir bool) bool {
+	result := false
+	for _, set := range rules {
+		sub := rel
+		if set.base != "" {
+			if !strings.HasPrefix(rel, set.base+"/") {
+				continue
+			}
+			sub = rel[len(set.base)+1:]
+		}
+		for _, p := range set.patterns {
+			if p.Match(sub, isDir) {
+				result = !p.negate
+			}
+		}
+	}
+	return result
+}
+
+func compileRules(base string, patterns []string) (ruleSet, error) {
+	set := ruleSet{base: base}
+	for _, pattern := range patterns {
+		p, err := CompilePattern(pattern)
+		if err != nil {
+			return set, err
+		}
+		set.patterns = append(set.patterns, p)
+	}
+	return set, nil
+}
+
+// readIgnoreFile parses a gitignore-format file. It returns nil if the file
+// does not exist; malformed lines are skipped as git does.
+func readIgnoreFile(file, base string) (*ruleSet, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	defer f.Close()
+
+	set := &ruleSet{base: base}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if p, err := CompilePattern(line); err == nil {
+			set.patterns = append(set.patterns, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	return set, nil
+}
+
+// isBinary reports whether a file contains a NUL byte near its start
+func isBinary(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}